@@ -4,8 +4,12 @@ import (
 	"clicker/pkg/client"
 	"context"
 	"crypto/rand"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	pb "clicker/gen/proto"
 
@@ -14,6 +18,14 @@ import (
 )
 
 func main() {
+	name := flag.String("name", "", "name of a new player (random if empty), a saved profile keeps its name")
+	profile := flag.String("profile", "clicker.profile", "file with the secret of the player profile, created on the first run")
+	room := flag.String("room", "", "id of the room to join (the default room if empty)")
+	newRoom := flag.String("new-room", "", "create a room with this name and join it")
+	capacity := flag.Int("capacity", 0, "player limit of the room created with -new-room (server default if 0)")
+	listRooms := flag.Bool("list-rooms", false, "print the open rooms and exit")
+	flag.Parse()
+
 	opts := []grpc.DialOption{
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 	}
@@ -31,15 +43,50 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	stream, err := grpcClient.PlayGame(ctx)
-	if err != nil {
-		log.Fatalf("Failed to start game stream: %v", err)
+	playerName := *name
+	if playerName == "" {
+		playerName = rand.Text()
 	}
-	log.Println("Game has started")
 
-	randomName := rand.Text()
+	if *listRooms {
+		rooms, err := grpcClient.ListRooms(ctx, &pb.ListRoomsRequest{})
+		if err != nil {
+			log.Fatalf("Could not list rooms: %v", err)
+		}
+		for _, r := range rooms.GetRooms() {
+			fmt.Printf("%s\t%s\t%d/%d игроков\tэтап %d\n", r.GetId(), r.GetName(), r.GetPlayers(), r.GetCapacity(), r.GetStage())
+		}
+		return
+	}
+
+	roomID := *room
+	if *newRoom != "" {
+		created, err := grpcClient.CreateRoom(ctx, &pb.CreateRoomRequest{Name: *newRoom, Capacity: int32(*capacity)})
+		if err != nil {
+			log.Fatalf("Could not create room: %v", err)
+		}
+		fmt.Printf("Created room '%s' with ID %s\n", created.GetName(), created.GetId())
+		roomID = created.GetId()
+	}
 
-	myPlayer := &pb.Player{Name: randomName}
-	app := client.NewClickerApp(stream, myPlayer)
+	myPlayer := &pb.Player{Name: playerName, RoomId: roomID, Secret: loadSecret(*profile)}
+	app := client.NewClickerApp(ctx, grpcClient, myPlayer)
 	app.Run()
 }
+
+// loadSecret reads the profile secret from path or creates a new one there.
+// If it can not be saved the player still gets a profile, but only for this run
+func loadSecret(path string) string {
+	data, err := os.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(data)) != "" {
+		return strings.TrimSpace(string(data))
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Could not read profile %s: %v", path, err)
+	}
+	secret := rand.Text()
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0o600); err != nil {
+		log.Printf("Could not save profile %s, progress will not be restored: %v", path, err)
+	}
+	return secret
+}
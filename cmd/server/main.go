@@ -4,64 +4,115 @@ import (
 	pb "clicker/gen/proto"
 	"clicker/pkg/game"
 	"clicker/pkg/server"
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net"
 	"os"
 	"os/signal"
-	"sync"
+	"strings"
 	"syscall"
+	"time"
 
 	"google.golang.org/grpc"
 )
 
 func main() {
-	gameInstance := game.NewGame()
+	bannedWords := flag.String("banned-words", "", "comma separated words masked in the chat")
+	flag.Parse()
+
 	closeChan := make(chan os.Signal, 1)
 	signal.Notify(closeChan, syscall.SIGINT, syscall.SIGTERM)
 
-	log.Println("Creating enemies and loading assets...")
-	var wg sync.WaitGroup
-
-	const numEnemies = 10
-	enemies := make([]*game.Enemy, numEnemies)
+	log.Println("Loading assets...")
 
-	for i := 0; i < numEnemies; i++ {
-		level := int64(i + 1)
-		wg.Add(1)
+	items, err := game.LoadItemCatalog("static/items.json")
+	if err != nil {
+		log.Fatalf("Could not load items: %v", err)
+	}
+	catalog, err := game.LoadEnemyCatalog("static/enemies.json", items)
+	if err != nil {
+		log.Fatalf("Could not load enemies: %v", err)
+	}
+	shop, err := game.LoadShopCatalog("static/shop.json", items)
+	if err != nil {
+		log.Fatalf("Could not load the shop: %v", err)
+	}
+	log.Println("All assets loaded")
 
-		go func(lvl int64, index int) {
-			defer wg.Done()
+	guildStore, err := game.NewFileGuildStore("data/guilds")
+	if err != nil {
+		log.Fatalf("Could not open guild store: %v", err)
+	}
+	guilds, err := game.NewGuildRegistry(guildStore)
+	if err != nil {
+		log.Fatalf("Could not load guilds: %v", err)
+	}
 
-			// TODO: change the hardcoded image value
-			imagePath := "static/images/goblin.webp"
+	tradeAudit, err := game.NewFileTradeAudit("data/trades.log")
+	if err != nil {
+		log.Fatalf("Could not open trade audit: %v", err)
+	}
 
-			preparedEnemy := gameInstance.CreateAndPrepareEnemy(lvl, imagePath)
-			enemies[index] = preparedEnemy
-		}(level, i)
+	// every room gets its own spawner so the stages progress separately
+	newGame := func() *game.Game {
+		gameInstance := game.NewGame()
+		gameInstance.Spawner = game.NewSpawner(catalog)
+		gameInstance.LootPolicy = game.LootToTopDamage
+		gameInstance.RewardPolicy = game.RewardProportional
+		gameInstance.Shop = shop.Offers
+		gameInstance.Guilds = guilds
+		gameInstance.TradeAudit = tradeAudit
+		if *bannedWords != "" {
+			gameInstance.ChatFilter = game.BannedWordsFilter(strings.Split(*bannedWords, ",")...)
+		}
+		gameInstance.FillEnemies()
+		return gameInstance
 	}
 
-	wg.Wait()
-	log.Println("All assets loaded and enemies are ready")
-	gameInstance.Enemies = enemies
+	gameCtx, stopGame := context.WithCancel(context.Background())
+	rooms := game.NewRoomManager(gameCtx, newGame)
+	go rooms.RunJanitor(gameCtx, game.EmptyRoomTTL/2)
+	go guilds.RunFlusher(gameCtx, game.GuildFlushInterval)
 
-	gameInstance.Lock()
-	fmt.Printf("Создано %d врагов\n", len(gameInstance.Enemies))
-	for _, e := range gameInstance.Enemies {
+	mainRoom, _ := rooms.Room(game.DefaultRoomID)
+	mainRoom.Game.Lock()
+	fmt.Printf("Создано %d врагов\n", len(mainRoom.Game.Enemies))
+	for _, e := range mainRoom.Game.Enemies {
 		fmt.Printf("Враг ID: %s\nLevel = %d\nMax HP = %.2f", e.ID, e.Level, e.MaxHealth)
 	}
-	gameInstance.Unlock()
+	mainRoom.Game.Unlock()
 
 	lis, err := net.Listen("tcp", "localhost:32228")
 	if err != nil {
 		log.Fatalf("Could not start listening on port :32228")
 	}
 
+	playerStore, err := game.NewFilePlayerStore("data/players")
+	if err != nil {
+		log.Fatalf("Could not open player store: %v", err)
+	}
+
 	fmt.Println("Game server init")
-	gameServer := server.NewGameServer(gameInstance)
+	gameServer := server.NewGameServer(rooms, playerStore, guilds)
 	var opts []grpc.ServerOption
 	grpcServer := grpc.NewServer(opts...)
 	pb.RegisterGameServiceServer(grpcServer, gameServer)
+	go gameServer.RunSessionSaver(gameCtx, game.SessionSaveInterval)
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-gameCtx.Done():
+				return
+			case <-ticker.C:
+				log.Printf("Delivery: %s", gameServer.DeliveryMetrics())
+			}
+		}
+	}()
 
 	go func() {
 		if err := grpcServer.Serve(lis); err != nil {
@@ -72,6 +123,22 @@ func main() {
 
 	<-closeChan
 	log.Println("Shutting down the server")
-	grpcServer.GracefulStop()
+	stopGame()
+	// streams only end when the clients leave, do not wait for them forever
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		grpcServer.Stop()
+	}
+	// escrowed goods go back to their owners before the profiles are saved
+	rooms.CancelTrades()
+	gameServer.SaveSessions()
+	guilds.Flush()
+	tradeAudit.Close()
 	log.Println("Server gracefully stopped :)")
 }
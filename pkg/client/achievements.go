@@ -0,0 +1,18 @@
+package client
+
+import (
+	"fmt"
+
+	pb "clicker/gen/proto"
+)
+
+func achievementDescription(unlocked *pb.AchievementUnlocked) string {
+	text := unlocked.GetDescription()
+	if gold := unlocked.GetRewardGold(); gold > 0 {
+		text += fmt.Sprintf("\nНаграда: %d золота", gold)
+	}
+	if points := unlocked.GetRewardStatPoints(); points > 0 {
+		text += fmt.Sprintf("\nНаграда: %d очков характеристик", points)
+	}
+	return text
+}
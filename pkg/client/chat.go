@@ -0,0 +1,66 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	pb "clicker/gen/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// the server keeps a shorter history, the client also remembers what came after joining
+const chatLinesLimit = 200
+
+// setChat replaces the chat with the room history, runs on the fyne thread
+func (a *ClickerApp) setChat(history []*pb.ChatMessage) {
+	a.chatMessages = append(a.chatMessages[:0], history...)
+	a.chatList.Refresh()
+	a.chatList.ScrollToBottom()
+}
+
+// addChatMessage appends a new message, runs on the fyne thread
+func (a *ClickerApp) addChatMessage(msg *pb.ChatMessage) {
+	a.chatMessages = append(a.chatMessages, msg)
+	if len(a.chatMessages) > chatLinesLimit {
+		a.chatMessages = a.chatMessages[len(a.chatMessages)-chatLinesLimit:]
+	}
+	a.chatList.Refresh()
+	a.chatList.ScrollToBottom()
+}
+
+func (a *ClickerApp) createChatContent() fyne.CanvasObject {
+	a.chatList = widget.NewList(
+		func() int { return len(a.chatMessages) },
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("")
+			label.Wrapping = fyne.TextWrapWord
+			return label
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			msg := a.chatMessages[i]
+			sentAt := time.Unix(msg.GetSentAt(), 0).Format("15:04")
+			o.(*widget.Label).SetText(fmt.Sprintf("[%s] %s: %s", sentAt, msg.GetPlayerName(), msg.GetText()))
+			a.chatList.SetItemHeight(i, o.MinSize().Height)
+		},
+	)
+
+	input := widget.NewEntry()
+	input.SetPlaceHolder("Сообщение...")
+	submit := func(text string) {
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		a.send(&pb.ClientToServer{Event: &pb.ClientToServer_Chat{Chat: &pb.ChatMessage{Text: text}}})
+		input.SetText("")
+	}
+	input.OnSubmitted = submit
+	sendButton := widget.NewButton("Отправить", func() { submit(input.Text) })
+
+	header := widget.NewLabelWithStyle("Чат", fyne.TextAlignCenter, fyne.TextStyle{Bold: true})
+	footer := container.NewBorder(nil, nil, nil, sendButton, input)
+	return container.NewBorder(header, footer, nil, nil, a.chatList)
+}
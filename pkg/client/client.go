@@ -2,81 +2,161 @@
 package client
 
 import (
-	"bytes"
-	"image/png"
+	"context"
+	"fmt"
 	"log"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	pb "clicker/gen/proto"
+	"clicker/pkg/game"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
-	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-type ClickerApp struct {
-	stream  pb.GameService_PlayGameClient
-	fyneApp fyne.App
-	mainWin fyne.Window
-	player  *pb.Player
+const (
+	reconnectBaseDelay   = 500 * time.Millisecond
+	reconnectMaxDelay    = 10 * time.Second
+	reconnectMaxAttempts = 10
+)
 
-	enemyName      binding.String
-	enemyCurrentHp binding.Float
-	enemyMaxHp     binding.Float
-	enemyImage     binding.Bytes
+type ClickerApp struct {
+	ctx      context.Context
+	client   pb.GameServiceClient
+	streamMu sync.Mutex
+	stream   pb.GameService_PlayGameClient
+	actionID atomic.Int64
+	fyneApp  fyne.App
+	mainWin  fyne.Window
+	player   *pb.Player
+
+	// owned by the listener goroutine, used to resume after a disconnect
+	selfInfo     *pb.Player
+	sessionToken string
+	lastSeq      int64
+
+	enemyCards      map[string]*enemyCard
+	enemyOrder      []string
+	selectedEnemyID string
+	enemyRow        *fyne.Container
+	stage           binding.Int
 
 	playerGold           binding.Int
 	playerLevel          binding.Int
 	playerExp            binding.Int
 	playerExpToNextLevel binding.Int
+	statPoints           binding.Int
+	prestigeInfo         binding.String
+	attributes           binding.String
 
 	weaponName   binding.String
 	weaponDamage binding.Float
+	critInfo     binding.String
 	weaponLevel  binding.Int
 
-	otherPlayers binding.StringList
+	inventoryItems []*pb.Item
+	inventoryList  *widget.List
+	armorLabel     *widget.Label
+	trinketLabel   *widget.Label
+
+	quests      []*pb.Quest
+	questList   *widget.List
+	questsReset *widget.Label
+
+	shopOffers []*pb.ShopOffer
+	shopList   *widget.List
+	buffs      binding.String
+
+	companions    []*pb.Companion
+	companionList *widget.List
+	companionsDps binding.Float
+
+	leaderboard     []*pb.LeaderboardEntry
+	leaderboardList *widget.List
+	boardCategory   pb.LeaderboardCategory
+	boardScope      pb.LeaderboardScope
+
+	chatMessages []*pb.ChatMessage
+	chatList     *widget.List
+
+	guild           *pb.Guild
+	guildInfo       *widget.Label
+	guildMemberList *widget.List
+	guildBuffList   *widget.List
+	guildView       *fyne.Container
+	guilds          []*pb.Guild
+	guildList       *widget.List
+	guildBrowser    *fyne.Container
+
+	roomPlayers  map[string]string // id -> name, without the player himself
+	trade        *pb.Trade
+	tradeStart   *fyne.Container
+	tradePartner *widget.Select
+	tradeView    *fyne.Container
+	tradeMine    *widget.Label
+	tradeTheirs  *widget.Label
+	tradeTimer   *widget.Label
+	tradeItems   *widget.CheckGroup
+	tradeItemIDs map[string]string // check label -> item id
+	// last thing worth telling the player about
+	notice binding.String
 }
 
-func NewClickerApp(stream pb.GameService_PlayGameClient, player *pb.Player) *ClickerApp {
+func NewClickerApp(ctx context.Context, client pb.GameServiceClient, player *pb.Player) *ClickerApp {
 	a := &ClickerApp{
-		stream:  stream,
-		player:  player,
-		fyneApp: app.New(),
+		ctx:      ctx,
+		client:   client,
+		player:   player,
+		selfInfo: &pb.Player{Name: player.GetName(), RoomId: player.GetRoomId(), Secret: player.GetSecret()},
+		fyneApp:  app.New(),
 
-		enemyName:      binding.NewString(),
-		enemyCurrentHp: binding.NewFloat(),
-		enemyMaxHp:     binding.NewFloat(),
-		enemyImage:     binding.NewBytes(),
+		enemyCards:  make(map[string]*enemyCard),
+		roomPlayers: make(map[string]string),
+		enemyRow:    container.NewHBox(),
+		stage:       binding.NewInt(),
 
 		playerGold:           binding.NewInt(),
 		playerLevel:          binding.NewInt(),
 		playerExp:            binding.NewInt(),
 		playerExpToNextLevel: binding.NewInt(),
+		statPoints:           binding.NewInt(),
+		buffs:                binding.NewString(),
+		prestigeInfo:         binding.NewString(),
+		companionsDps:        binding.NewFloat(),
+		attributes:           binding.NewString(),
 
 		weaponName:   binding.NewString(),
 		weaponDamage: binding.NewFloat(),
+		critInfo:     binding.NewString(),
 		weaponLevel:  binding.NewInt(),
 
-		otherPlayers: binding.NewStringList(),
+		armorLabel:   widget.NewLabel(""),
+		trinketLabel: widget.NewLabel(""),
+
+		notice: binding.NewString(),
 	}
 	a.mainWin = a.fyneApp.NewWindow("Clicker")
 	return a
 }
 
 func (a *ClickerApp) Run() {
-	err := a.stream.Send(&pb.ClientToServer{
-		Event: &pb.ClientToServer_SelfInfo{SelfInfo: a.player},
-	})
+	received, err := a.connect()
 	if err != nil {
-		log.Fatalf("Could not send self info: %v", err)
+		log.Fatalf("Could not start game stream: %v", err)
 	}
-	log.Println("Sent self info to server")
+	log.Println("Game has started")
 
-	go a.listenForServerUpdates()
+	go a.listenForServerUpdates(received)
 
 	a.mainWin.SetContent(a.createContent())
 	a.mainWin.Resize(fyne.NewSize(800, 600))
@@ -84,6 +164,116 @@ func (a *ClickerApp) Run() {
 	log.Println("Application shutting down")
 }
 
+// connect opens a new stream and either resumes the previous session or joins the game.
+// It only succeeds once the server welcomed us, the messages received before that
+// (missed events replayed on resume) are returned together with the welcome
+func (a *ClickerApp) connect() ([]*pb.ServerToClient, error) {
+	stream, err := a.client.PlayGame(a.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	handshake := &pb.ClientToServer{
+		Event: &pb.ClientToServer_SelfInfo{SelfInfo: a.selfInfo},
+	}
+	if a.sessionToken != "" {
+		handshake = &pb.ClientToServer{
+			Event: &pb.ClientToServer_Resume{
+				Resume: &pb.ResumeSession{
+					SessionToken: a.sessionToken,
+					LastSeq:      a.lastSeq,
+				},
+			},
+		}
+	}
+	if err := stream.Send(handshake); err != nil {
+		return nil, err
+	}
+	log.Println("Sent handshake to server")
+
+	// the server rejects a handshake by closing the stream, so wait for the welcome
+	var received []*pb.ServerToClient
+	for {
+		in, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		received = append(received, in)
+		if in.GetWelcome() != nil {
+			break
+		}
+	}
+
+	a.streamMu.Lock()
+	a.stream = stream
+	a.streamMu.Unlock()
+	return received, nil
+}
+
+// isPermanent tells whether retrying the handshake can't help, e.g. the room is full.
+// AlreadyExists is not, the server takes the old session over once it is in the game
+func isPermanent(err error) bool {
+	switch status.Code(err) {
+	case codes.ResourceExhausted, codes.InvalidArgument, codes.PermissionDenied, codes.FailedPrecondition:
+		return true
+	}
+	return false
+}
+
+// reconnect retries connect with exponential backoff until the server welcomes us.
+// It gives up after reconnectMaxAttempts or as soon as the server rejects us for good
+func (a *ClickerApp) reconnect() ([]*pb.ServerToClient, error) {
+	delay := reconnectBaseDelay
+	var err error
+	for attempt := 1; attempt <= reconnectMaxAttempts; attempt++ {
+		select {
+		case <-a.ctx.Done():
+			return nil, a.ctx.Err()
+		case <-time.After(delay):
+		}
+
+		log.Printf("Reconnecting to server, attempt %d/%d", attempt, reconnectMaxAttempts)
+		var received []*pb.ServerToClient
+		received, err = a.connect()
+		if err == nil {
+			return received, nil
+		}
+		log.Printf("Reconnect failed: %v", err)
+
+		if status.Code(err) == codes.NotFound {
+			if a.sessionToken == "" {
+				// the room we were playing in is gone
+				return nil, err
+			}
+			// the server forgot our session, join again with our profile
+			a.sessionToken = ""
+			a.lastSeq = 0
+		} else if isPermanent(err) {
+			return nil, err
+		}
+
+		delay *= 2
+		if delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+	}
+	return nil, err
+}
+
+func (a *ClickerApp) currentStream() pb.GameService_PlayGameClient {
+	a.streamMu.Lock()
+	defer a.streamMu.Unlock()
+	return a.stream
+}
+
+// send numbers the action so a rejection of it can be matched in the logs
+func (a *ClickerApp) send(msg *pb.ClientToServer) {
+	msg.CorrelationId = strconv.FormatInt(a.actionID.Add(1), 10)
+	if err := a.currentStream().Send(msg); err != nil {
+		log.Printf("Could not send to server: %v", err)
+	}
+}
+
 func (a *ClickerApp) updatePlayerData(playerData *pb.Player) {
 	if playerData == nil {
 		return
@@ -92,30 +282,61 @@ func (a *ClickerApp) updatePlayerData(playerData *pb.Player) {
 	a.playerLevel.Set(int(playerData.GetStats().GetLevel()))
 	a.playerExp.Set(int(playerData.GetStats().GetExperience()))
 	a.playerExpToNextLevel.Set(int(playerData.GetStats().GetNextLevelExp()))
+	a.statPoints.Set(int(playerData.GetStats().GetStatPoints()))
+	prestige := playerData.GetPrestige()
+	a.prestigeInfo.Set(fmt.Sprintf("Души: %d, перерождений: %d (за перерождение: %d)", prestige.GetSouls(), prestige.GetCount(), game.RebirthSouls(playerData)))
+	attributes := playerData.GetStats().GetAttributes()
+	a.attributes.Set(fmt.Sprintf("Сила %d, Точность %d, Удача %d", attributes.GetStrength(), attributes.GetPrecision(), attributes.GetFortune()))
 	if weapon := playerData.GetEquipment().GetWeapon(); weapon != nil {
 		a.weaponName.Set(weapon.GetName())
 		a.weaponLevel.Set(int(weapon.GetLevel()))
-		damage := weapon.GetBaseDamage() + weapon.GetDamageGrowth()*float32(weapon.GetLevel()-1)
-		a.weaponDamage.Set(float64(damage))
+		a.weaponDamage.Set(game.PlayerDamage(playerData))
+		a.critInfo.Set(fmt.Sprintf("Крит: %.0f%% (x%.1f)", game.PlayerCritChance(playerData)*100, game.BaseCritMultiplier))
 	}
-}
-
-func (a *ClickerApp) updateEnemyData(enemyData *pb.Enemy) {
-	if enemyData == nil {
-		return
+	if a.inventoryList != nil {
+		a.updateInventory(playerData)
+	}
+	if a.companionList != nil {
+		a.updateCompanions(playerData)
+	}
+	a.updateBuffs(playerData)
+	if a.questList != nil {
+		a.updateQuests(playerData)
 	}
-	a.enemyName.Set(enemyData.GetName())
-	a.enemyCurrentHp.Set(enemyData.GetCurrentHp())
-	a.enemyMaxHp.Set(enemyData.GetMaxHp())
-	a.enemyImage.Set(enemyData.GetImage())
 }
 
-func (a *ClickerApp) listenForServerUpdates() {
+// listenForServerUpdates handles the messages received during the handshake first, then reads the stream
+func (a *ClickerApp) listenForServerUpdates(received []*pb.ServerToClient) {
 	for {
-		in, err := a.stream.Recv()
-		if err != nil {
-			log.Printf("Failed to receive from stream: %v", err)
-			return
+		if len(received) == 0 {
+			in, err := a.currentStream().Recv()
+			if err != nil {
+				log.Printf("Failed to receive from stream: %v", err)
+				if status.Code(err) == codes.NotFound {
+					// the server forgot our session, join again with our profile
+					a.sessionToken = ""
+					a.lastSeq = 0
+				}
+				received, err = a.reconnect()
+				if err != nil {
+					log.Printf("Giving up on reconnecting to server: %v", err)
+					fyne.Do(func() {
+						a.notice.Set(disconnectMessage(err))
+					})
+					return
+				}
+				continue
+			}
+			received = append(received, in)
+		}
+		in := received[0]
+		received = received[1:]
+
+		a.lastSeq = in.GetSeq()
+		if welcome := in.GetWelcome(); welcome != nil {
+			a.sessionToken = welcome.GetSessionToken()
+			// a rejoin after the session expired goes back to the same room
+			a.selfInfo = &pb.Player{Name: welcome.GetPlayer().GetName(), RoomId: welcome.GetRoom().GetId(), Secret: a.selfInfo.GetSecret()}
 		}
 
 		fyne.Do(func() {
@@ -126,19 +347,17 @@ func (a *ClickerApp) listenForServerUpdates() {
 				log.Printf("WELCOME! I am %s with ID %s", playerData.GetName(), playerData.GetId())
 				a.player = playerData
 				a.updatePlayerData(playerData)
+				a.mainWin.SetTitle(fmt.Sprintf("Clicker — %s", event.Welcome.GetRoom().GetName()))
+				a.send(&pb.ClientToServer{Event: &pb.ClientToServer_ListShop{ListShop: &pb.ListShopRequest{}}})
 
 			case *pb.ServerToClient_InitialState:
 				initState := event.InitialState
-				log.Printf("INITIAL STATE: Got enemy and %d players.", len(initState.GetPlayers()))
-				a.updateEnemyData(initState.GetEnemy())
-
-				var otherPlayerNames []string
-				for _, p := range initState.GetPlayers() {
-					if p.GetId() != a.player.GetId() {
-						otherPlayerNames = append(otherPlayerNames, p.GetName())
-					}
-				}
-				a.otherPlayers.Set(otherPlayerNames)
+				log.Printf("INITIAL STATE: Got %d enemies and %d players.", len(initState.GetEnemies()), len(initState.GetPlayers()))
+				a.setEnemies(initState.GetEnemies())
+				a.stage.Set(int(initState.GetStage()))
+				a.setChat(initState.GetChat())
+				a.setRoomPlayers(initState.GetPlayers())
+				a.refreshLeaderboard()
 
 			case *pb.ServerToClient_PlayerStateUpdate:
 				playerData := event.PlayerStateUpdate.GetPlayer()
@@ -150,20 +369,102 @@ func (a *ClickerApp) listenForServerUpdates() {
 			case *pb.ServerToClient_PlayerJoined:
 				newPlayer := event.PlayerJoined.GetPlayer()
 				log.Printf("Player %s joined the game", newPlayer.GetName())
-				a.otherPlayers.Append(newPlayer.GetName())
+				a.roomPlayers[newPlayer.GetId()] = newPlayer.GetName()
+				a.refreshTradePartners()
+				a.refreshLeaderboard()
 
 			case *pb.ServerToClient_PlayerLeft:
 				leftPlayerID := event.PlayerLeft.GetPlayerId()
 				log.Printf("Player with ID %s left the game", leftPlayerID)
-				// TODO: delete by id, not name
+				delete(a.roomPlayers, leftPlayerID)
+				a.refreshTradePartners()
+				a.refreshLeaderboard()
 
 			case *pb.ServerToClient_GameStateUpdate:
 				update := event.GameStateUpdate
-				a.enemyCurrentHp.Set(update.GetEnemyCurrentHp())
+				a.updateEnemy(update)
+				if hit := update.GetLastHit(); hit.GetCritical() && hit.GetAttackerId() == a.player.GetId() {
+					a.notice.Set(fmt.Sprintf("Критический удар: %.1f", hit.GetDamageDealt()))
+				}
 
 			case *pb.ServerToClient_EnemySpawned:
 				newEnemy := event.EnemySpawned.GetEnemy()
-				a.updateEnemyData(newEnemy)
+				a.addEnemy(newEnemy)
+
+			case *pb.ServerToClient_EnemyDefeated:
+				a.removeEnemy(event.EnemyDefeated.GetEnemyId())
+
+			case *pb.ServerToClient_LootDropped:
+				loot := event.LootDropped
+				log.Printf("Player %s looted '%s'", loot.GetRecipientId(), loot.GetItem().GetName())
+				if loot.GetRecipientId() == a.player.GetId() {
+					a.notice.Set("Добыча: " + itemDescription(loot.GetItem()))
+				}
+
+			case *pb.ServerToClient_KillSummary:
+				for _, share := range event.KillSummary.GetShares() {
+					if share.GetPlayerId() == a.player.GetId() {
+						notice := fmt.Sprintf("Вклад %.0f%%: +%d золота, +%d опыта", share.GetShare()*100, share.GetGold(), share.GetExperience())
+						if share.GetGuildTax() > 0 {
+							notice += fmt.Sprintf(" (%d в казну гильдии)", share.GetGuildTax())
+						}
+						a.notice.Set(notice)
+					}
+				}
+
+			case *pb.ServerToClient_WelcomeBack:
+				back := event.WelcomeBack
+				away := time.Duration(back.GetOfflineSeconds()) * time.Second
+				a.notice.Set(fmt.Sprintf("С возвращением! За %s: %d врагов, +%d золота, +%d опыта",
+					away.Round(time.Minute), back.GetKills(), back.GetGold(), back.GetExperience()))
+
+			case *pb.ServerToClient_LevelUp:
+				levelUp := event.LevelUp
+				log.Printf("Player %s reached level %d", levelUp.GetPlayerId(), levelUp.GetLevel())
+				if levelUp.GetPlayerId() == a.player.GetId() {
+					a.notice.Set(fmt.Sprintf("Новый уровень %d! Очков характеристик: %d", levelUp.GetLevel(), levelUp.GetStatPoints()))
+				}
+
+			case *pb.ServerToClient_AchievementUnlocked:
+				unlocked := event.AchievementUnlocked
+				log.Printf("Achievement unlocked: %s", unlocked.GetAchievementId())
+				dialog.ShowInformation("Достижение: "+unlocked.GetName(), achievementDescription(unlocked), a.mainWin)
+
+			case *pb.ServerToClient_Chat:
+				a.addChatMessage(event.Chat)
+
+			case *pb.ServerToClient_TradeUpdate:
+				a.setTrade(event.TradeUpdate)
+
+			case *pb.ServerToClient_GuildUpdate:
+				a.setGuild(event.GuildUpdate.GetGuild())
+
+			case *pb.ServerToClient_ShopList:
+				a.setShopOffers(event.ShopList.GetOffers())
+
+			case *pb.ServerToClient_BossEscaped:
+				escaped := event.BossEscaped
+				log.Printf("Boss %s escaped, back to stage %d", escaped.GetEnemyId(), escaped.GetResetStage())
+				a.setEnemies(escaped.GetEnemies())
+				a.stage.Set(int(escaped.GetResetStage()))
+				a.notice.Set(fmt.Sprintf("Босс сбежал! Возвращаемся на этап %d", escaped.GetResetStage()))
+
+			case *pb.ServerToClient_Reborn:
+				reborn := event.Reborn
+				log.Printf("Player %s was reborn, %d souls total", reborn.GetPlayerId(), reborn.GetTotalSouls())
+				if reborn.GetPlayerId() == a.player.GetId() {
+					a.notice.Set(fmt.Sprintf("Перерождение! +%d душ, всего %d", reborn.GetSoulsEarned(), reborn.GetTotalSouls()))
+				}
+
+			case *pb.ServerToClient_ActionRejected:
+				rejected := event.ActionRejected
+				log.Printf("Action %s rejected: %s (%s)", rejected.GetCorrelationId(), rejected.GetCode(), rejected.GetReason())
+				a.notice.Set(rejectionMessage(rejected))
+
+			case *pb.ServerToClient_StageCleared:
+				cleared := event.StageCleared
+				log.Printf("Stage %d cleared, moving on to stage %d", cleared.GetStage(), cleared.GetNextStage())
+				a.stage.Set(int(cleared.GetNextStage()))
 
 			default:
 				log.Printf("Received an unknown event type: %T", event)
@@ -173,36 +474,17 @@ func (a *ClickerApp) listenForServerUpdates() {
 }
 
 func (a *ClickerApp) createContent() fyne.CanvasObject {
-	enemyNameLabel := widget.NewLabelWithData(a.enemyName)
-	enemyHpBar := widget.NewProgressBar()
-	a.enemyCurrentHp.AddListener(binding.NewDataListener(func() {
-		cur, _ := a.enemyCurrentHp.Get()
-		max, _ := a.enemyMaxHp.Get()
-		if max > 0 {
-			enemyHpBar.SetValue(cur / max)
-		}
-	}))
-	enemyImage := &canvas.Image{FillMode: canvas.ImageFillContain}
-	enemyImage.SetMinSize(fyne.NewSize(256, 256))
-	a.enemyImage.AddListener(binding.NewDataListener(func() {
-		enemyImageBytes, _ := a.enemyImage.Get()
-		if len(enemyImageBytes) == 0 {
-			return
-		}
-		go func() {
-			img, _ := png.Decode(bytes.NewReader(enemyImageBytes))
-			fyne.Do(func() { enemyImage.Image = img; enemyImage.Refresh() })
-		}()
-	}))
 	attackButton := widget.NewButton("Attack", func() {
-		a.stream.Send(&pb.ClientToServer{Event: &pb.ClientToServer_Attack{Attack: &pb.AttackAction{}}})
+		a.send(&pb.ClientToServer{Event: &pb.ClientToServer_Attack{Attack: &pb.AttackAction{EnemyId: a.selectedEnemyID}}})
 	})
 
+	stageLabel := widget.NewLabelWithData(binding.IntToStringWithFormat(a.stage, "Этап: %d"))
+
 	enemyBox := container.NewVBox(
-		container.NewCenter(enemyNameLabel),
-		container.NewCenter(enemyImage),
-		enemyHpBar,
+		container.NewCenter(stageLabel),
+		container.NewCenter(a.enemyRow),
 		layout.NewSpacer(),
+		container.NewCenter(widget.NewLabelWithData(a.notice)),
 		attackButton,
 	)
 
@@ -218,8 +500,28 @@ func (a *ClickerApp) createContent() fyne.CanvasObject {
 	}))
 	weaponNameLabel := widget.NewLabelWithData(a.weaponName)
 	weaponStatsLabel := widget.NewLabelWithData(binding.FloatToStringWithFormat(a.weaponDamage, "Урон: %.1f"))
+	critLabel := widget.NewLabelWithData(a.critInfo)
 	upgradeWeaponButton := widget.NewButton("Улучшить", func() {
-		a.stream.Send(&pb.ClientToServer{Event: &pb.ClientToServer_UpgradeWeapon{UpgradeWeapon: &pb.UpgradeWeaponRequest{}}})
+		a.send(&pb.ClientToServer{Event: &pb.ClientToServer_UpgradeWeapon{UpgradeWeapon: &pb.UpgradeWeaponRequest{}}})
+	})
+
+	allocate := func(attribute pb.Attribute) func() {
+		return func() {
+			a.send(&pb.ClientToServer{Event: &pb.ClientToServer_AllocateStatPoints{AllocateStatPoints: &pb.AllocateStatPoints{Attribute: attribute, Points: 1}}})
+		}
+	}
+	statsBox := container.NewVBox(
+		widget.NewLabelWithData(binding.IntToStringWithFormat(a.statPoints, "Очки характеристик: %d")),
+		widget.NewLabelWithData(a.attributes),
+		container.NewGridWithColumns(3,
+			widget.NewButton("+Сила", allocate(pb.Attribute_ATTRIBUTE_STRENGTH)),
+			widget.NewButton("+Точность", allocate(pb.Attribute_ATTRIBUTE_PRECISION)),
+			widget.NewButton("+Удача", allocate(pb.Attribute_ATTRIBUTE_FORTUNE)),
+		),
+	)
+
+	rebirthButton := widget.NewButton("Переродиться", func() {
+		a.send(&pb.ClientToServer{Event: &pb.ClientToServer_Rebirth{Rebirth: &pb.RebirthRequest{}}})
 	})
 
 	playerBox := container.NewVBox(
@@ -227,27 +529,25 @@ func (a *ClickerApp) createContent() fyne.CanvasObject {
 		playerGoldLabel,
 		playerLevelLabel,
 		playerExpBar,
-		container.NewHSplit(container.NewVBox(weaponNameLabel, weaponStatsLabel), upgradeWeaponButton),
+		container.NewHSplit(container.NewVBox(weaponNameLabel, weaponStatsLabel, critLabel), upgradeWeaponButton),
+		statsBox,
+		container.NewBorder(nil, nil, nil, rebirthButton, widget.NewLabelWithData(a.prestigeInfo)),
 	)
 
-	othersList := widget.NewListWithData(
-		a.otherPlayers,
-		func() fyne.CanvasObject { return widget.NewLabel("") },
-		func(i binding.DataItem, o fyne.CanvasObject) {
-			o.(*widget.Label).Bind(i.(binding.String))
-		},
-	)
+	leftPanel := container.NewVSplit(playerBox, container.NewHSplit(a.createLeaderboardContent(), a.createChatContent()))
+	leftPanel.Offset = 0.6
 
-	othersBox := container.NewBorder(
-		widget.NewLabelWithStyle("Онлайн", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
-		nil, nil, nil,
-		othersList,
+	tabs := container.NewAppTabs(
+		container.NewTabItem("Бой", enemyBox),
+		container.NewTabItem("Инвентарь", a.createInventoryContent()),
+		container.NewTabItem("Помощники", a.createCompanionsContent()),
+		container.NewTabItem("Магазин", a.createShopContent()),
+		container.NewTabItem("Задания", a.createQuestsContent()),
+		container.NewTabItem("Гильдия", a.createGuildContent()),
+		container.NewTabItem("Обмен", a.createTradeContent()),
 	)
 
-	leftPanel := container.NewVSplit(playerBox, othersBox)
-	leftPanel.Offset = 0.6
-
-	mainLayout := container.NewHSplit(leftPanel, enemyBox)
+	mainLayout := container.NewHSplit(leftPanel, tabs)
 	mainLayout.Offset = 0.3
 
 	return mainLayout
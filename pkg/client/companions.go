@@ -0,0 +1,54 @@
+package client
+
+import (
+	"fmt"
+
+	pb "clicker/gen/proto"
+	"clicker/pkg/game"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/binding"
+	"fyne.io/fyne/v2/widget"
+)
+
+// updateCompanions shows the hired helpers of the player, runs on the fyne thread
+func (a *ClickerApp) updateCompanions(player *pb.Player) {
+	a.companions = player.GetCompanions()
+	var dps float64
+	for _, companion := range a.companions {
+		dps += companion.GetDps()
+	}
+	a.companionsDps.Set(dps)
+	a.companionList.Refresh()
+}
+
+func (a *ClickerApp) ownedCompanions(id string) int64 {
+	for _, companion := range a.companions {
+		if companion.GetCompanionId() == id {
+			return companion.GetCount()
+		}
+	}
+	return 0
+}
+
+func (a *ClickerApp) createCompanionsContent() fyne.CanvasObject {
+	a.companionList = widget.NewList(
+		func() int { return len(game.CompanionTypes) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, nil, widget.NewButton("Нанять", nil), widget.NewLabel(""))
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			kind := &game.CompanionTypes[i]
+			owned := a.ownedCompanions(kind.ID)
+			row := o.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s x%d — %.0f урона/с каждый, цена %d", kind.Name, owned, kind.DPS, kind.HireCost(owned)))
+			row.Objects[1].(*widget.Button).OnTapped = func() {
+				a.send(&pb.ClientToServer{Event: &pb.ClientToServer_HireCompanion{HireCompanion: &pb.HireCompanion{CompanionId: kind.ID}}})
+			}
+		},
+	)
+
+	header := widget.NewLabelWithData(binding.FloatToStringWithFormat(a.companionsDps, "Урон помощников: %.1f/с"))
+	return container.NewBorder(header, nil, nil, nil, a.companionList)
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"math"
+
+	pb "clicker/gen/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// enemyCard is one selectable enemy in the enemy row, only touched from the fyne thread
+type enemyCard struct {
+	enemy        *pb.Enemy
+	nameLabel    *widget.Label
+	hpBar        *widget.ProgressBar
+	timerLabel   *widget.Label
+	image        *canvas.Image
+	selectButton *widget.Button
+	box          *fyne.Container
+}
+
+func (a *ClickerApp) newEnemyCard(enemy *pb.Enemy) *enemyCard {
+	card := &enemyCard{
+		enemy:      enemy,
+		nameLabel:  widget.NewLabel(enemy.GetName()),
+		hpBar:      widget.NewProgressBar(),
+		timerLabel: widget.NewLabel(""),
+		image:      &canvas.Image{FillMode: canvas.ImageFillContain},
+	}
+	card.image.SetMinSize(fyne.NewSize(160, 160))
+	card.selectButton = widget.NewButton("Цель", func() {
+		a.selectEnemy(enemy.GetId())
+	})
+	card.setHp(enemy.GetCurrentHp())
+	card.setTimeLeft(enemy.GetTimeLeft())
+	if !enemy.GetBoss() {
+		card.timerLabel.Hide()
+	}
+
+	if imageBytes := enemy.GetImage(); len(imageBytes) > 0 {
+		go func() {
+			img, _ := png.Decode(bytes.NewReader(imageBytes))
+			fyne.Do(func() { card.image.Image = img; card.image.Refresh() })
+		}()
+	}
+
+	defenceLabel := widget.NewLabel(fmt.Sprintf("Броня: %.0f  Реген: %.1f/с", enemy.GetArmor(), enemy.GetHpRegen()))
+
+	card.box = container.NewVBox(
+		container.NewCenter(card.nameLabel),
+		container.NewCenter(card.image),
+		card.hpBar,
+		container.NewCenter(card.timerLabel),
+		container.NewCenter(defenceLabel),
+		card.selectButton,
+	)
+	return card
+}
+
+func (c *enemyCard) setHp(hp float64) {
+	c.enemy.CurrentHp = hp
+	if max := c.enemy.GetMaxHp(); max > 0 {
+		c.hpBar.SetValue(hp / max)
+	}
+}
+
+func (c *enemyCard) setTimeLeft(seconds float64) {
+	if !c.enemy.GetBoss() {
+		return
+	}
+	c.enemy.TimeLeft = seconds
+	c.timerLabel.SetText(fmt.Sprintf("Босс сбежит через %.0f с", math.Max(seconds, 0)))
+}
+
+func (a *ClickerApp) setEnemies(enemies []*pb.Enemy) {
+	a.enemyCards = make(map[string]*enemyCard, len(enemies))
+	a.enemyOrder = a.enemyOrder[:0]
+	for _, enemy := range enemies {
+		a.enemyCards[enemy.GetId()] = a.newEnemyCard(enemy)
+		a.enemyOrder = append(a.enemyOrder, enemy.GetId())
+	}
+	if _, ok := a.enemyCards[a.selectedEnemyID]; !ok {
+		a.selectedEnemyID = ""
+	}
+	a.refreshEnemyRow()
+}
+
+func (a *ClickerApp) addEnemy(enemy *pb.Enemy) {
+	if enemy == nil {
+		return
+	}
+	if _, ok := a.enemyCards[enemy.GetId()]; !ok {
+		a.enemyOrder = append(a.enemyOrder, enemy.GetId())
+	}
+	a.enemyCards[enemy.GetId()] = a.newEnemyCard(enemy)
+	a.refreshEnemyRow()
+}
+
+func (a *ClickerApp) removeEnemy(enemyID string) {
+	if _, ok := a.enemyCards[enemyID]; !ok {
+		return
+	}
+	delete(a.enemyCards, enemyID)
+	for i, id := range a.enemyOrder {
+		if id == enemyID {
+			a.enemyOrder = append(a.enemyOrder[:i], a.enemyOrder[i+1:]...)
+			break
+		}
+	}
+	if a.selectedEnemyID == enemyID {
+		a.selectedEnemyID = ""
+	}
+	a.refreshEnemyRow()
+}
+
+func (a *ClickerApp) updateEnemy(update *pb.GameStateUpdate) {
+	if update.GetEnemyCurrentHp() <= 0 {
+		a.removeEnemy(update.GetEnemyId())
+		return
+	}
+	if card, ok := a.enemyCards[update.GetEnemyId()]; ok {
+		card.setHp(update.GetEnemyCurrentHp())
+		card.setTimeLeft(update.GetBossTimeLeft())
+	}
+}
+
+func (a *ClickerApp) selectEnemy(enemyID string) {
+	a.selectedEnemyID = enemyID
+	a.refreshEnemyRow()
+}
+
+// refreshEnemyRow rebuilds the row and makes sure some enemy is always selected
+func (a *ClickerApp) refreshEnemyRow() {
+	if a.selectedEnemyID == "" && len(a.enemyOrder) > 0 {
+		a.selectedEnemyID = a.enemyOrder[0]
+	}
+
+	objects := make([]fyne.CanvasObject, 0, len(a.enemyOrder))
+	for _, id := range a.enemyOrder {
+		card := a.enemyCards[id]
+		if id == a.selectedEnemyID {
+			card.selectButton.Importance = widget.HighImportance
+		} else {
+			card.selectButton.Importance = widget.MediumImportance
+		}
+		card.selectButton.Refresh()
+		objects = append(objects, card.box)
+	}
+	a.enemyRow.Objects = objects
+	a.enemyRow.Refresh()
+}
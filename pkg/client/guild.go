@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "clicker/gen/proto"
+	"clicker/pkg/game"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+var guildRoleNames = map[pb.GuildRole]string{
+	pb.GuildRole_GUILD_ROLE_MEMBER:  "участник",
+	pb.GuildRole_GUILD_ROLE_OFFICER: "офицер",
+	pb.GuildRole_GUILD_ROLE_LEADER:  "лидер",
+}
+
+// setGuild shows the guild of the player, nil means he has none. Runs on the fyne thread
+func (a *ClickerApp) setGuild(guild *pb.Guild) {
+	a.guild = guild
+	if guild == nil {
+		a.guildView.Hide()
+		a.guildBrowser.Show()
+		a.refreshGuilds()
+		return
+	}
+	a.guildBrowser.Hide()
+	a.guildView.Show()
+
+	buffs := make([]string, 0, len(guild.GetBuffs()))
+	for _, buff := range guild.GetBuffs() {
+		left := time.Until(time.Unix(buff.GetExpiresAt(), 0)).Round(time.Second)
+		if left > 0 {
+			buffs = append(buffs, fmt.Sprintf("%s +%.0f%% (%s)", buff.GetName(), buff.GetValue()*100, left))
+		}
+	}
+	info := fmt.Sprintf("Гильдия «%s»: %d/%d игроков, в казне %d золота, налог %.0f%%",
+		guild.GetName(), len(guild.GetMembers()), game.MaxGuildMembers, guild.GetBank(), guild.GetTaxRate()*100)
+	if len(buffs) > 0 {
+		info += "\nУсиления: " + strings.Join(buffs, ", ")
+	}
+	a.guildInfo.SetText(info)
+	a.guildMemberList.Refresh()
+	a.guildBuffList.Refresh()
+}
+
+// myGuildRole is the role of the player in his guild
+func (a *ClickerApp) myGuildRole() pb.GuildRole {
+	for _, member := range a.guild.GetMembers() {
+		if member.GetPlayerId() == a.player.GetId() {
+			return member.GetRole()
+		}
+	}
+	return pb.GuildRole_GUILD_ROLE_UNSPECIFIED
+}
+
+// refreshGuilds loads the guilds the player can join, runs on the fyne thread
+func (a *ClickerApp) refreshGuilds() {
+	go func() {
+		ctx, cancel := context.WithTimeout(a.ctx, 5*time.Second)
+		defer cancel()
+		list, err := a.client.ListGuilds(ctx, &pb.ListGuildsRequest{})
+		if err != nil {
+			log.Printf("Could not load guilds: %v", err)
+			return
+		}
+		fyne.Do(func() {
+			a.guilds = list.GetGuilds()
+			a.guildList.Refresh()
+		})
+	}()
+}
+
+func (a *ClickerApp) createGuildContent() fyne.CanvasObject {
+	// the player has no guild: found one or join an existing one
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Название гильдии")
+	createButton := widget.NewButton("Основать", func() {
+		a.send(&pb.ClientToServer{Event: &pb.ClientToServer_CreateGuild{CreateGuild: &pb.CreateGuildRequest{Name: nameEntry.Text}}})
+		nameEntry.SetText("")
+	})
+	a.guildList = widget.NewList(
+		func() int { return len(a.guilds) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, nil, widget.NewButton("Вступить", nil), widget.NewLabel(""))
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			guild := a.guilds[i]
+			row := o.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s — %d/%d игроков, налог %.0f%%",
+				guild.GetName(), len(guild.GetMembers()), game.MaxGuildMembers, guild.GetTaxRate()*100))
+			row.Objects[1].(*widget.Button).OnTapped = func() {
+				a.send(&pb.ClientToServer{Event: &pb.ClientToServer_JoinGuild{JoinGuild: &pb.JoinGuildRequest{GuildId: guild.GetId()}}})
+			}
+		},
+	)
+	browserHeader := container.NewVBox(
+		container.NewBorder(nil, nil, nil, createButton, nameEntry),
+		container.NewBorder(nil, nil, nil, widget.NewButton("Обновить", a.refreshGuilds), widget.NewLabel("Гильдии")),
+	)
+	a.guildBrowser = container.NewBorder(browserHeader, nil, nil, nil, a.guildList)
+
+	// the player is in a guild: roster, bank and buffs
+	a.guildInfo = widget.NewLabel("")
+	a.guildMemberList = widget.NewList(
+		func() int { return len(a.guild.GetMembers()) },
+		func() fyne.CanvasObject {
+			buttons := container.NewHBox(widget.NewButton("Офицер", nil), widget.NewButton("Лидер", nil))
+			return container.NewBorder(nil, nil, nil, buttons, widget.NewLabel(""))
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			member := a.guild.GetMembers()[i]
+			row := o.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s — %s", member.GetPlayerName(), guildRoleNames[member.GetRole()]))
+
+			buttons := row.Objects[1].(*fyne.Container)
+			roleButton := buttons.Objects[0].(*widget.Button)
+			leaderButton := buttons.Objects[1].(*widget.Button)
+			setRole := func(role pb.GuildRole) {
+				a.send(&pb.ClientToServer{Event: &pb.ClientToServer_SetGuildRole{SetGuildRole: &pb.SetGuildRoleRequest{PlayerId: member.GetPlayerId(), Role: role}}})
+			}
+			if a.myGuildRole() != pb.GuildRole_GUILD_ROLE_LEADER || member.GetPlayerId() == a.player.GetId() {
+				buttons.Hide()
+				return
+			}
+			buttons.Show()
+			if member.GetRole() == pb.GuildRole_GUILD_ROLE_OFFICER {
+				roleButton.SetText("Разжаловать")
+				roleButton.OnTapped = func() { setRole(pb.GuildRole_GUILD_ROLE_MEMBER) }
+			} else {
+				roleButton.SetText("Офицер")
+				roleButton.OnTapped = func() { setRole(pb.GuildRole_GUILD_ROLE_OFFICER) }
+			}
+			leaderButton.OnTapped = func() { setRole(pb.GuildRole_GUILD_ROLE_LEADER) }
+		},
+	)
+
+	a.guildBuffList = widget.NewList(
+		func() int { return len(game.GuildBuffOffers) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, nil, widget.NewButton("Купить", nil), widget.NewLabel(""))
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			offer := game.GuildBuffOffers[i]
+			row := o.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s: +%.0f%% урона всей гильдии на %s, цена %d",
+				offer.Name, offer.Value*100, offer.Duration, offer.Price))
+			button := row.Objects[1].(*widget.Button)
+			if a.myGuildRole() < pb.GuildRole_GUILD_ROLE_OFFICER {
+				button.Disable()
+			} else {
+				button.Enable()
+			}
+			button.OnTapped = func() {
+				a.send(&pb.ClientToServer{Event: &pb.ClientToServer_BuyGuildBuff{BuyGuildBuff: &pb.BuyGuildBuffRequest{BuffId: offer.ID}}})
+			}
+		},
+	)
+
+	taxEntry := widget.NewEntry()
+	taxEntry.SetPlaceHolder(fmt.Sprintf("Налог, %% (до %.0f)", game.MaxGuildTaxRate*100))
+	taxButton := widget.NewButton("Изменить налог", func() {
+		percent, err := strconv.ParseFloat(taxEntry.Text, 64)
+		if err != nil {
+			a.notice.Set("Налог должен быть числом")
+			return
+		}
+		a.send(&pb.ClientToServer{Event: &pb.ClientToServer_SetGuildTax{SetGuildTax: &pb.SetGuildTaxRequest{TaxRate: percent / 100}}})
+		taxEntry.SetText("")
+	})
+	leaveButton := widget.NewButton("Покинуть гильдию", func() {
+		a.send(&pb.ClientToServer{Event: &pb.ClientToServer_LeaveGuild{LeaveGuild: &pb.LeaveGuildRequest{}}})
+	})
+
+	viewHeader := container.NewVBox(a.guildInfo, container.NewBorder(nil, nil, nil, taxButton, taxEntry))
+	lists := container.NewVSplit(a.guildMemberList, a.guildBuffList)
+	a.guildView = container.NewBorder(viewHeader, leaveButton, nil, nil, lists)
+	a.guildView.Hide()
+
+	return container.NewStack(a.guildBrowser, a.guildView)
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	pb "clicker/gen/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+var rarityNames = map[pb.ItemRarity]string{
+	pb.ItemRarity_ITEM_RARITY_COMMON:    "обычный",
+	pb.ItemRarity_ITEM_RARITY_UNCOMMON:  "необычный",
+	pb.ItemRarity_ITEM_RARITY_RARE:      "редкий",
+	pb.ItemRarity_ITEM_RARITY_EPIC:      "эпический",
+	pb.ItemRarity_ITEM_RARITY_LEGENDARY: "легендарный",
+}
+
+func itemDescription(item *pb.Item) string {
+	if item == nil {
+		return "—"
+	}
+
+	parts := []string{fmt.Sprintf("%s [%s]", item.GetName(), rarityNames[item.GetRarity()])}
+	if weapon := item.GetWeapon(); weapon != nil {
+		parts = append(parts, fmt.Sprintf("ур. %d, урон %.1f (+%.1f/ур.)", weapon.GetLevel(), weapon.GetBaseDamage(), weapon.GetDamageGrowth()))
+	}
+	if bonus := item.GetBonusDamage(); bonus != 0 {
+		parts = append(parts, fmt.Sprintf("+%.1f урона", bonus))
+	}
+	if goldFind := item.GetGoldFind(); goldFind != 0 {
+		parts = append(parts, fmt.Sprintf("+%.0f%% золота", goldFind*100))
+	}
+	if critChance := item.GetCritChance(); critChance != 0 {
+		parts = append(parts, fmt.Sprintf("+%.0f%% крит", critChance*100))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// updateInventory shows the inventory and the equipped gear of the player, runs on the fyne thread
+func (a *ClickerApp) updateInventory(player *pb.Player) {
+	a.inventoryItems = player.GetInventory()
+	a.inventoryList.Refresh()
+	a.updateTradeItems()
+
+	equipment := player.GetEquipment()
+	a.armorLabel.SetText("Броня: " + itemDescription(equipment.GetArmor()))
+	a.trinketLabel.SetText("Амулет: " + itemDescription(equipment.GetTrinket()))
+}
+
+func (a *ClickerApp) createInventoryContent() fyne.CanvasObject {
+	a.inventoryList = widget.NewList(
+		func() int { return len(a.inventoryItems) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, nil, widget.NewButton("Надеть", nil), widget.NewLabel(""))
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			item := a.inventoryItems[i]
+			row := o.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(itemDescription(item))
+			row.Objects[1].(*widget.Button).OnTapped = func() {
+				a.send(&pb.ClientToServer{Event: &pb.ClientToServer_EquipItem{EquipItem: &pb.EquipItemRequest{ItemId: item.GetId()}}})
+			}
+		},
+	)
+
+	unequip := func(slot pb.ItemSlot) func() {
+		return func() {
+			a.send(&pb.ClientToServer{Event: &pb.ClientToServer_UnequipItem{UnequipItem: &pb.UnequipItemRequest{Slot: slot}}})
+		}
+	}
+
+	equipmentBox := container.NewVBox(
+		widget.NewLabelWithStyle("Снаряжение", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		container.NewBorder(nil, nil, nil, widget.NewButton("Снять", unequip(pb.ItemSlot_ITEM_SLOT_ARMOR)), a.armorLabel),
+		container.NewBorder(nil, nil, nil, widget.NewButton("Снять", unequip(pb.ItemSlot_ITEM_SLOT_TRINKET)), a.trinketLabel),
+		widget.NewLabelWithStyle("Инвентарь", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+	)
+
+	return container.NewBorder(equipmentBox, nil, nil, nil, a.inventoryList)
+}
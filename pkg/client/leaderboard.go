@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	pb "clicker/gen/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const leaderboardRefreshInterval = 15 * time.Second
+
+var leaderboardCategories = []struct {
+	category pb.LeaderboardCategory
+	name     string
+}{
+	{pb.LeaderboardCategory_LEADERBOARD_CATEGORY_TOTAL_DAMAGE, "Урон"},
+	{pb.LeaderboardCategory_LEADERBOARD_CATEGORY_KILLS, "Убийства"},
+	{pb.LeaderboardCategory_LEADERBOARD_CATEGORY_HIGHEST_LEVEL, "Уровень"},
+	{pb.LeaderboardCategory_LEADERBOARD_CATEGORY_GOLD_EARNED, "Золото"},
+}
+
+const (
+	scopeAllTime = "За всё время"
+	scopeSession = "За сессию"
+)
+
+// refreshLeaderboard asks the server for the selected ranking, runs on the fyne thread
+func (a *ClickerApp) refreshLeaderboard() {
+	req := &pb.LeaderboardRequest{Category: a.boardCategory, Scope: a.boardScope, RoomId: a.player.GetRoomId()}
+	go func() {
+		ctx, cancel := context.WithTimeout(a.ctx, 5*time.Second)
+		defer cancel()
+		board, err := a.client.GetLeaderboard(ctx, req)
+		if err != nil {
+			log.Printf("Could not load leaderboard: %v", err)
+			return
+		}
+		fyne.Do(func() {
+			if board.GetCategory() != a.boardCategory || board.GetScope() != a.boardScope {
+				// the player switched the ranking while we were waiting
+				return
+			}
+			a.leaderboard = board.GetEntries()
+			a.leaderboardList.Refresh()
+		})
+	}()
+}
+
+// leaderboardLoop keeps the rankings fresh while the app runs
+func (a *ClickerApp) leaderboardLoop() {
+	ticker := time.NewTicker(leaderboardRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			fyne.Do(a.refreshLeaderboard)
+		}
+	}
+}
+
+func (a *ClickerApp) createLeaderboardContent() fyne.CanvasObject {
+	a.leaderboardList = widget.NewList(
+		func() int { return len(a.leaderboard) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			entry := a.leaderboard[i]
+			text := fmt.Sprintf("%d. %s — %d", entry.GetRank(), entry.GetPlayerName(), entry.GetValue())
+			if entry.GetOnline() {
+				text += " ●"
+			}
+			o.(*widget.Label).SetText(text)
+		},
+	)
+
+	names := make([]string, 0, len(leaderboardCategories))
+	for _, c := range leaderboardCategories {
+		names = append(names, c.name)
+	}
+	categorySelect := widget.NewSelect(names, func(name string) {
+		for _, c := range leaderboardCategories {
+			if c.name == name {
+				a.boardCategory = c.category
+			}
+		}
+		a.refreshLeaderboard()
+	})
+	scopeRadio := widget.NewRadioGroup([]string{scopeAllTime, scopeSession}, func(scope string) {
+		a.boardScope = pb.LeaderboardScope_LEADERBOARD_SCOPE_ALL_TIME
+		if scope == scopeSession {
+			a.boardScope = pb.LeaderboardScope_LEADERBOARD_SCOPE_SESSION
+		}
+		a.refreshLeaderboard()
+	})
+	scopeRadio.Horizontal = true
+	scopeRadio.Required = true
+	scopeRadio.SetSelected(scopeAllTime)
+	categorySelect.SetSelected(names[0])
+
+	header := container.NewVBox(
+		widget.NewLabelWithStyle("Рейтинг", fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		categorySelect,
+		scopeRadio,
+	)
+	go a.leaderboardLoop()
+	return container.NewBorder(header, nil, nil, nil, a.leaderboardList)
+}
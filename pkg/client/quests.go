@@ -0,0 +1,56 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	pb "clicker/gen/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// updateQuests shows the daily quests of the player, runs on the fyne thread
+func (a *ClickerApp) updateQuests(player *pb.Player) {
+	quests := player.GetQuests()
+	a.quests = quests.GetQuests()
+	a.questList.Refresh()
+	if resetsAt := quests.GetResetsAt(); resetsAt > 0 {
+		a.questsReset.SetText("Новые задания: " + time.Unix(resetsAt, 0).Format("02.01 15:04"))
+	}
+}
+
+func (a *ClickerApp) createQuestsContent() fyne.CanvasObject {
+	a.questsReset = widget.NewLabel("")
+	a.questList = widget.NewList(
+		func() int { return len(a.quests) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, nil, widget.NewButton("Забрать", nil), widget.NewLabel(""))
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			quest := a.quests[i]
+			row := o.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s: %d/%d, награда %d золота",
+				quest.GetDescription(), quest.GetProgress(), quest.GetTarget(), quest.GetRewardGold()))
+
+			button := row.Objects[1].(*widget.Button)
+			switch {
+			case quest.GetClaimed():
+				button.SetText("Получено")
+				button.Disable()
+			case quest.GetProgress() < quest.GetTarget():
+				button.SetText("Забрать")
+				button.Disable()
+			default:
+				button.SetText("Забрать")
+				button.Enable()
+			}
+			button.OnTapped = func() {
+				a.send(&pb.ClientToServer{Event: &pb.ClientToServer_ClaimQuest{ClaimQuest: &pb.ClaimQuestRequest{QuestId: quest.GetId()}}})
+			}
+		},
+	)
+
+	return container.NewBorder(a.questsReset, nil, nil, nil, a.questList)
+}
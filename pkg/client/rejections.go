@@ -0,0 +1,48 @@
+package client
+
+import (
+	pb "clicker/gen/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var rejectionMessages = map[pb.RejectionCode]string{
+	pb.RejectionCode_REJECTION_CODE_INSUFFICIENT_GOLD: "Недостаточно золота",
+	pb.RejectionCode_REJECTION_CODE_UNKNOWN_TARGET:    "Цель не найдена",
+	pb.RejectionCode_REJECTION_CODE_RATE_LIMITED:      "Слишком быстро, помедленнее",
+	pb.RejectionCode_REJECTION_CODE_INVALID_STATE:     "Сейчас это сделать нельзя",
+	pb.RejectionCode_REJECTION_CODE_INVALID_ARGUMENT:  "Сообщение не отправлено",
+}
+
+func rejectionMessage(rejected *pb.ActionRejected) string {
+	message, ok := rejectionMessages[rejected.GetCode()]
+	if !ok {
+		return "Действие отклонено: " + rejected.GetReason()
+	}
+	detailed := rejected.GetCode() == pb.RejectionCode_REJECTION_CODE_INVALID_STATE || rejected.GetCode() == pb.RejectionCode_REJECTION_CODE_INVALID_ARGUMENT
+	if detailed && rejected.GetReason() != "" {
+		return message + ": " + rejected.GetReason()
+	}
+	return message
+}
+
+var disconnectMessages = map[codes.Code]string{
+	codes.AlreadyExists:     "Этот профиль уже играет в другом окне",
+	codes.ResourceExhausted: "Сервер перегружен или комната заполнена",
+	codes.NotFound:          "Комната больше не существует",
+	codes.Unavailable:       "Сервер недоступен",
+}
+
+// disconnectMessage explains to the player why the client stopped reconnecting
+func disconnectMessage(err error) string {
+	st := status.Convert(err)
+	message, ok := disconnectMessages[st.Code()]
+	if !ok {
+		message = "Соединение с сервером потеряно"
+	}
+	if st.Message() != "" {
+		return message + ": " + st.Message()
+	}
+	return message
+}
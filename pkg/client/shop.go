@@ -0,0 +1,64 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	pb "clicker/gen/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+var buffNames = map[pb.BuffType]string{
+	pb.BuffType_BUFF_TYPE_DAMAGE:    "урона",
+	pb.BuffType_BUFF_TYPE_GOLD_FIND: "золота",
+}
+
+func offerDescription(offer *pb.ShopOffer) string {
+	if item := offer.GetItem(); item != nil {
+		return fmt.Sprintf("%s — %d золота", itemDescription(item), offer.GetPrice())
+	}
+	return fmt.Sprintf("%s: +%.0f%% %s на %d с — %d золота",
+		offer.GetName(), offer.GetBuffValue()*100, buffNames[offer.GetBuffType()], offer.GetDurationSeconds(), offer.GetPrice())
+}
+
+// setShopOffers shows what the shop sells, runs on the fyne thread
+func (a *ClickerApp) setShopOffers(offers []*pb.ShopOffer) {
+	a.shopOffers = offers
+	if a.shopList != nil {
+		a.shopList.Refresh()
+	}
+}
+
+func (a *ClickerApp) updateBuffs(player *pb.Player) {
+	if len(player.GetBuffs()) == 0 {
+		a.buffs.Set("Активных эффектов нет")
+		return
+	}
+	parts := make([]string, 0, len(player.GetBuffs()))
+	for _, buff := range player.GetBuffs() {
+		parts = append(parts, fmt.Sprintf("%s (%.0f с)", buff.GetName(), buff.GetTimeLeft()))
+	}
+	a.buffs.Set("Эффекты: " + strings.Join(parts, ", "))
+}
+
+func (a *ClickerApp) createShopContent() fyne.CanvasObject {
+	a.shopList = widget.NewList(
+		func() int { return len(a.shopOffers) },
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, nil, widget.NewButton("Купить", nil), widget.NewLabel(""))
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			offer := a.shopOffers[i]
+			row := o.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(offerDescription(offer))
+			row.Objects[1].(*widget.Button).OnTapped = func() {
+				a.send(&pb.ClientToServer{Event: &pb.ClientToServer_Buy{Buy: &pb.BuyRequest{OfferId: offer.GetId()}}})
+			}
+		},
+	)
+
+	return container.NewBorder(widget.NewLabelWithData(a.buffs), nil, nil, nil, a.shopList)
+}
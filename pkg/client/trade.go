@@ -0,0 +1,154 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "clicker/gen/proto"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+var tradeEndNotices = map[pb.TradeStatus]string{
+	pb.TradeStatus_TRADE_STATUS_COMPLETED: "Обмен завершён",
+	pb.TradeStatus_TRADE_STATUS_CANCELLED: "Обмен отменён",
+	pb.TradeStatus_TRADE_STATUS_EXPIRED:   "Время обмена истекло",
+}
+
+// setRoomPlayers remembers who can be asked for a trade, runs on the fyne thread
+func (a *ClickerApp) setRoomPlayers(players []*pb.Player) {
+	a.roomPlayers = make(map[string]string, len(players))
+	for _, player := range players {
+		if player.GetId() != a.player.GetId() {
+			a.roomPlayers[player.GetId()] = player.GetName()
+		}
+	}
+	a.refreshTradePartners()
+}
+
+func (a *ClickerApp) refreshTradePartners() {
+	options := make([]string, 0, len(a.roomPlayers))
+	for _, name := range a.roomPlayers {
+		options = append(options, name)
+	}
+	a.tradePartner.SetOptions(options)
+}
+
+// setTrade shows the current trade, runs on the fyne thread
+func (a *ClickerApp) setTrade(trade *pb.Trade) {
+	if notice, ok := tradeEndNotices[trade.GetStatus()]; ok {
+		a.notice.Set(notice)
+		a.trade = nil
+		a.tradeView.Hide()
+		a.tradeStart.Show()
+		return
+	}
+
+	a.trade = trade
+	mine, theirs := trade.GetInitiator(), trade.GetPartner()
+	if mine.GetPlayerId() != a.player.GetId() {
+		mine, theirs = theirs, mine
+	}
+	a.tradeTheirs.SetText(fmt.Sprintf("%s предлагает:\n%s", theirs.GetPlayerName(), tradeOfferDescription(theirs)))
+	a.tradeMine.SetText("Вы предлагаете:\n" + tradeOfferDescription(mine))
+	a.tradeTimer.SetText(fmt.Sprintf("Осталось %s", (time.Duration(trade.GetTimeLeft()) * time.Second).Round(time.Second)))
+	a.tradeStart.Hide()
+	a.tradeView.Show()
+}
+
+func tradeOfferDescription(offer *pb.TradeOffer) string {
+	lines := []string{fmt.Sprintf("%d золота", offer.GetGold())}
+	for _, item := range offer.GetItems() {
+		lines = append(lines, itemDescription(item))
+	}
+	switch {
+	case offer.GetConfirmed():
+		lines = append(lines, "✔ подтверждено")
+	case offer.GetLocked():
+		lines = append(lines, "🔒 зафиксировано")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// updateTradeItems lets the player offer any item of his inventory, runs on the fyne thread
+func (a *ClickerApp) updateTradeItems() {
+	a.tradeItemIDs = make(map[string]string, len(a.inventoryItems))
+	options := make([]string, 0, len(a.inventoryItems))
+	for _, item := range a.inventoryItems {
+		label := fmt.Sprintf("%s (%s)", item.GetName(), item.GetId()[:min(6, len(item.GetId()))])
+		a.tradeItemIDs[label] = item.GetId()
+		options = append(options, label)
+	}
+	a.tradeItems.Options = options
+	a.tradeItems.Refresh()
+}
+
+func (a *ClickerApp) createTradeContent() fyne.CanvasObject {
+	a.tradePartner = widget.NewSelect(nil, nil)
+	a.tradePartner.PlaceHolder = "Игрок"
+	proposeButton := widget.NewButton("Предложить обмен", func() {
+		for id, name := range a.roomPlayers {
+			if name == a.tradePartner.Selected {
+				a.send(&pb.ClientToServer{Event: &pb.ClientToServer_ProposeTrade{ProposeTrade: &pb.ProposeTradeRequest{PartnerId: id}}})
+				return
+			}
+		}
+	})
+	a.tradeStart = container.NewVBox(widget.NewLabel("Выберите игрока из этой комнаты"), a.tradePartner, proposeButton)
+
+	a.tradeTheirs = widget.NewLabel("")
+	a.tradeMine = widget.NewLabel("")
+	a.tradeTimer = widget.NewLabel("")
+	goldEntry := widget.NewEntry()
+	goldEntry.SetPlaceHolder("Золото")
+	a.tradeItems = widget.NewCheckGroup(nil, nil)
+
+	offerButton := widget.NewButton("Предложить", func() {
+		if a.trade == nil {
+			return
+		}
+		text := strings.TrimSpace(goldEntry.Text)
+		if text == "" {
+			text = "0"
+		}
+		gold, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			a.notice.Set("Золото должно быть числом")
+			return
+		}
+		ids := make([]string, 0, len(a.tradeItems.Selected))
+		for _, label := range a.tradeItems.Selected {
+			ids = append(ids, a.tradeItemIDs[label])
+		}
+		a.send(&pb.ClientToServer{Event: &pb.ClientToServer_OfferTrade{OfferTrade: &pb.TradeOfferRequest{TradeId: a.trade.GetId(), Gold: gold, ItemIds: ids}}})
+	})
+	// the trade id is only known once the server opened the trade
+	tradeButton := func(label string, event func(tradeID string) *pb.ClientToServer) *widget.Button {
+		return widget.NewButton(label, func() {
+			if a.trade != nil {
+				a.send(event(a.trade.GetId()))
+			}
+		})
+	}
+	lockButton := tradeButton("Зафиксировать", func(id string) *pb.ClientToServer {
+		return &pb.ClientToServer{Event: &pb.ClientToServer_LockTrade{LockTrade: &pb.LockTradeRequest{TradeId: id}}}
+	})
+	confirmButton := tradeButton("Подтвердить", func(id string) *pb.ClientToServer {
+		return &pb.ClientToServer{Event: &pb.ClientToServer_ConfirmTrade{ConfirmTrade: &pb.ConfirmTradeRequest{TradeId: id}}}
+	})
+	cancelButton := tradeButton("Отменить", func(id string) *pb.ClientToServer {
+		return &pb.ClientToServer{Event: &pb.ClientToServer_CancelTrade{CancelTrade: &pb.CancelTradeRequest{TradeId: id}}}
+	})
+
+	offers := container.NewGridWithColumns(2, a.tradeMine, a.tradeTheirs)
+	editor := container.NewBorder(goldEntry, nil, nil, nil, container.NewVScroll(a.tradeItems))
+	buttons := container.NewGridWithColumns(4, offerButton, lockButton, confirmButton, cancelButton)
+	a.tradeView = container.NewBorder(container.NewVBox(a.tradeTimer, offers), buttons, nil, nil, editor)
+	a.tradeView.Hide()
+
+	return container.NewStack(a.tradeStart, a.tradeView)
+}
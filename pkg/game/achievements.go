@@ -0,0 +1,81 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"log"
+	"slices"
+)
+
+// Achievement unlocks once the counter of its event reaches Threshold
+type Achievement struct {
+	ID          string
+	Name        string
+	Description string
+	Event       EventKind
+	Threshold   int64
+
+	RewardGold       int64
+	RewardStatPoints int64
+}
+
+var DefaultAchievements = []*Achievement{
+	{ID: "first_blood", Name: "Первая кровь", Description: "Победить первого врага", Event: EventKill, Threshold: 1, RewardGold: 10},
+	{ID: "hunter", Name: "Охотник", Description: "Победить 100 врагов", Event: EventKill, Threshold: 100, RewardGold: 500, RewardStatPoints: 1},
+	{ID: "slayer", Name: "Истребитель", Description: "Победить 1000 врагов", Event: EventKill, Threshold: 1000, RewardGold: 5000, RewardStatPoints: 3},
+	{ID: "clicker", Name: "Кликер", Description: "Ударить 1000 раз", Event: EventClick, Threshold: 1000, RewardGold: 200},
+	{ID: "carpal_tunnel", Name: "Туннельный синдром", Description: "Ударить 10000 раз", Event: EventClick, Threshold: 10000, RewardStatPoints: 2},
+	{ID: "rich", Name: "Богач", Description: "Заработать 10000 золота", Event: EventGoldEarned, Threshold: 10000, RewardStatPoints: 1},
+	{ID: "blacksmith", Name: "Кузнец", Description: "Улучшить оружие 10 раз", Event: EventWeaponUpgraded, Threshold: 10, RewardGold: 300},
+	{ID: "veteran", Name: "Ветеран", Description: "Достичь 10 уровня", Event: EventLevelReached, Threshold: 10, RewardGold: 1000},
+	{ID: "legend", Name: "Легенда", Description: "Достичь 25 уровня", Event: EventLevelReached, Threshold: 25, RewardStatPoints: 5},
+}
+
+// trackAchievements updates the counters of the player and unlocks whatever the event completed
+func (g *Game) trackAchievements(event GameEvent) {
+	session, ok := g.Players[event.PlayerID]
+	if !ok {
+		return
+	}
+	player := session.Data
+	if player.Counters == nil {
+		player.Counters = make(map[string]int64)
+	}
+
+	counter := event.Kind.String()
+	if event.Kind == EventLevelReached {
+		player.Counters[counter] = max(player.Counters[counter], event.Amount)
+	} else {
+		player.Counters[counter] += event.Amount
+	}
+
+	for _, achievement := range g.Achievements {
+		if achievement.Event != event.Kind || player.Counters[counter] < achievement.Threshold {
+			continue
+		}
+		if slices.Contains(player.GetAchievements(), achievement.ID) {
+			continue
+		}
+		g.unlockAchievement(player, achievement)
+	}
+}
+
+func (g *Game) unlockAchievement(player *pb.Player, achievement *Achievement) {
+	player.Achievements = append(player.Achievements, achievement.ID)
+	player.Resources.Gold += achievement.RewardGold
+	player.Stats.StatPoints += achievement.RewardStatPoints
+
+	log.Printf("Player %s unlocked achievement '%s'", player.GetName(), achievement.Name)
+	g.sendToPlayer(player.GetId(), &pb.ServerToClient{
+		Event: &pb.ServerToClient_AchievementUnlocked{
+			AchievementUnlocked: &pb.AchievementUnlocked{
+				PlayerId:         player.GetId(),
+				AchievementId:    achievement.ID,
+				Name:             achievement.Name,
+				Description:      achievement.Description,
+				RewardGold:       achievement.RewardGold,
+				RewardStatPoints: achievement.RewardStatPoints,
+			},
+		},
+	})
+	g.sendPlayerState(player)
+}
@@ -0,0 +1,59 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBusDeliversInOrder(t *testing.T) {
+	var bus EventBus
+	var got []string
+	bus.Subscribe(func(e GameEvent) { got = append(got, "first "+e.Kind.String()) })
+	bus.Subscribe(func(e GameEvent) { got = append(got, "second "+e.Kind.String()) })
+
+	bus.Publish(GameEvent{Kind: EventKill, PlayerID: "a", Amount: 1})
+	assert.Equal(t, []string{"first kills", "second kills"}, got)
+}
+
+func TestAchievementsUnlockOnce(t *testing.T) {
+	game := NewGame()
+	game.Achievements = []*Achievement{
+		{ID: "two_clicks", Name: "Two clicks", Event: EventClick, Threshold: 2, RewardGold: 100, RewardStatPoints: 1},
+		{ID: "level_3", Name: "Level 3", Event: EventLevelReached, Threshold: 3},
+	}
+	player := InitializePlayer("Tester")
+	updates := NewOutbox(nil)
+	game.AddPlayer(player, updates)
+	game.CreateEnemy(EnemyStats{EnemyMaxHp: 1e9, EnemyLevel: 1}, "Wall", nil)
+	gold := player.GetResources().GetGold()
+
+	require.NoError(t, game.Attack("", player.GetId()))
+	assert.Empty(t, player.GetAchievements())
+	require.NoError(t, game.Attack("", player.GetId()))
+	require.NoError(t, game.Attack("", player.GetId()))
+
+	assert.Equal(t, []string{"two_clicks"}, player.GetAchievements())
+	assert.Equal(t, int64(3), player.GetCounters()["clicks"])
+	assert.Equal(t, gold+100, player.GetResources().GetGold(), "rewards are granted once")
+	assert.Equal(t, int64(1), player.GetStats().GetStatPoints())
+
+	var unlocked []*pb.AchievementUnlocked
+	for updates.Len() > 0 {
+		if u := updates.Pop().GetAchievementUnlocked(); u != nil {
+			unlocked = append(unlocked, u)
+		}
+	}
+	require.Len(t, unlocked, 1)
+	assert.Equal(t, "two_clicks", unlocked[0].GetAchievementId())
+
+	// levels are a maximum, not a sum
+	game.Lock()
+	game.publish(EventLevelReached, player.GetId(), 2)
+	game.publish(EventLevelReached, player.GetId(), 2)
+	game.Unlock()
+	assert.Equal(t, int64(2), player.GetCounters()["level"])
+	assert.NotContains(t, player.GetAchievements(), "level_3")
+}
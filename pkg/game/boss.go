@@ -0,0 +1,84 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"log"
+	"time"
+)
+
+const (
+	BossHpMultiplier     = 5.0
+	BossRewardMultiplier = 3.0
+	BossTimeLimit        = 30 * time.Second
+)
+
+// makeBoss turns a freshly spawned enemy into a stronger one with a kill timer
+func (e *Enemy) makeBoss() {
+	e.Boss = true
+	e.Name = "Boss " + e.Name
+	e.MaxHealth *= BossHpMultiplier
+	e.CurrentHealth = e.MaxHealth
+	e.HpRegen *= BossHpMultiplier
+	e.GoldMultiplier = rewardMultiplier(e.GoldMultiplier) * BossRewardMultiplier
+	e.ExpMultiplier = rewardMultiplier(e.ExpMultiplier) * BossRewardMultiplier
+	e.TimeLeft = BossTimeLimit.Seconds()
+}
+
+// tickBosses runs the timers of the bosses players are fighting, bosses in line wait
+func (g *Game) tickBosses(seconds float64) {
+	for _, enemy := range g.activeEnemies() {
+		if !enemy.Boss {
+			continue
+		}
+
+		enemy.TimeLeft -= seconds
+		if enemy.TimeLeft <= 0 {
+			g.bossEscaped(enemy)
+			// the enemies are all new now
+			return
+		}
+		g.broadcastToAll(&pb.ServerToClient{
+			Event: &pb.ServerToClient_GameStateUpdate{
+				GameStateUpdate: &pb.GameStateUpdate{
+					EnemyId:        enemy.ID,
+					EnemyCurrentHp: enemy.CurrentHealth,
+					BossTimeLeft:   enemy.TimeLeft,
+				},
+			},
+		})
+	}
+}
+
+// bossEscaped throws the room back to the start of the previous stage
+func (g *Game) bossEscaped(boss *Enemy) {
+	resetStage := max(boss.Stage-1, 1)
+	log.Printf("%s escaped, going back to stage %d", boss.Name, resetStage)
+
+	if g.Spawner == nil {
+		// nowhere to go back to, the boss just gets another try
+		boss.CurrentHealth = boss.MaxHealth
+		boss.TimeLeft = BossTimeLimit.Seconds()
+		boss.damageDealt = nil
+		resetStage = boss.Stage
+	} else {
+		clear(g.Enemies)
+		g.Enemies = g.Enemies[:0]
+		g.Spawner.ResetToStage(resetStage)
+		g.fillEnemies()
+	}
+
+	enemies := make([]*pb.Enemy, 0, g.ActiveEnemies)
+	for _, enemy := range g.activeEnemies() {
+		enemies = append(enemies, enemy.ToProto())
+	}
+	g.broadcastToAll(&pb.ServerToClient{
+		Event: &pb.ServerToClient_BossEscaped{
+			BossEscaped: &pb.BossEscaped{
+				EnemyId:    boss.ID,
+				Stage:      boss.Stage,
+				ResetStage: resetStage,
+				Enemies:    enemies,
+			},
+		},
+	})
+}
@@ -0,0 +1,65 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBossEscapesToPreviousStage(t *testing.T) {
+	game := NewGame()
+	game.ActiveEnemies = 1
+	game.Spawner = NewSpawner(nil)
+	game.Spawner.EnemiesPerStage = 2
+	game.FillEnemies()
+
+	updates := NewOutbox(nil)
+	player := InitializePlayer("Tester")
+	game.AddPlayer(player, updates)
+
+	// levels 1-3, the fourth one is the boss of stage 2
+	for i := 0; i < 3; i++ {
+		require.NoError(t, game.ApplyDamage("", 1e9, pb.DamageType_DAMAGE_TYPE_PHYSICAL, player.GetId()))
+	}
+	boss := game.GetCurrentEnemy()
+	require.True(t, boss.Boss)
+	assert.Equal(t, int64(2), boss.Stage)
+
+	game.Tick(BossTimeLimit / 2)
+	assert.Equal(t, boss.ID, game.GetCurrentEnemy().ID, "the boss is still there while the timer runs")
+	assert.InDelta(t, BossTimeLimit.Seconds()/2, boss.TimeLeft, 1e-9)
+
+	for updates.Len() > 0 {
+		updates.Pop()
+	}
+	game.Tick(BossTimeLimit)
+
+	first := game.GetCurrentEnemy()
+	assert.NotEqual(t, boss.ID, first.ID)
+	assert.Equal(t, int64(1), first.Level, "the room starts the previous stage over")
+	assert.Equal(t, int64(1), game.CurrentStage())
+
+	var escaped *pb.BossEscaped
+	for updates.Len() > 0 {
+		if e := updates.Pop().GetBossEscaped(); e != nil {
+			escaped = e
+		}
+	}
+	require.NotNil(t, escaped)
+	assert.Equal(t, boss.ID, escaped.GetEnemyId())
+	assert.Equal(t, int64(1), escaped.GetResetStage())
+	require.Len(t, escaped.GetEnemies(), 1)
+	assert.Equal(t, first.ID, escaped.GetEnemies()[0].GetId())
+}
+
+func TestBossGivesMoreRewards(t *testing.T) {
+	boss := &Enemy{MaxHealth: 100, CurrentHealth: 100, GoldMultiplier: 2}
+	boss.makeBoss()
+
+	assert.Equal(t, 500.0, boss.CurrentHealth)
+	assert.Equal(t, 2*BossRewardMultiplier, boss.GoldMultiplier)
+	assert.Equal(t, BossRewardMultiplier, boss.ExpMultiplier)
+	assert.Equal(t, BossTimeLimit.Seconds(), boss.TimeLeft)
+}
@@ -0,0 +1,340 @@
+package game
+
+import (
+	"bytes"
+	pb "clicker/gen/proto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"sync"
+)
+
+const enemyImageSize = 384
+
+// EnemyArchetype describes one kind of enemy the spawner can pick for a level
+type EnemyArchetype struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Image string `json:"image"`
+
+	// levels this archetype shows up at, MaxLevel 0 means no upper bound
+	MinLevel int64 `json:"min_level"`
+	MaxLevel int64 `json:"max_level"`
+	// relative chance to be picked among the archetypes of the same level
+	Weight int `json:"weight"`
+
+	// hp = BaseHp * HpGrowth^(level-1)
+	BaseHp          float64            `json:"base_hp"`
+	HpGrowth        float64            `json:"hp_growth"`
+	ArmorPerLevel   float64            `json:"armor_per_level"`
+	HpRegenPerStage float64            `json:"hp_regen_per_stage"`
+	Resistances     map[string]float64 `json:"resistances"` // damage type name ("fire") -> value
+
+	GoldMultiplier float64 `json:"gold_multiplier"`
+	ExpMultiplier  float64 `json:"exp_multiplier"`
+
+	Loot *LootTable `json:"loot"`
+
+	resistances map[pb.DamageType]float64
+	imageBytes  []byte
+}
+
+type EnemyCatalog struct {
+	Archetypes []*EnemyArchetype `json:"archetypes"`
+}
+
+// ItemCatalog holds every item the loot tables and the shop can hand out
+type ItemCatalog struct {
+	Items []*ItemTemplate `json:"items"`
+}
+
+// DefaultEnemyCatalog is the plain goblin curve used when no catalog file is given
+func DefaultEnemyCatalog() *EnemyCatalog {
+	return &EnemyCatalog{
+		Archetypes: []*EnemyArchetype{{
+			ID:              "goblin",
+			Name:            "Goblin",
+			MinLevel:        1,
+			Weight:          1,
+			BaseHp:          BaseHp,
+			HpGrowth:        Multiplier,
+			ArmorPerLevel:   ArmorPerLevel,
+			HpRegenPerStage: HpRegenPerStage,
+			GoldMultiplier:  1,
+			ExpMultiplier:   1,
+		}},
+	}
+}
+
+// LoadItemCatalog reads and validates the item catalog file
+func LoadItemCatalog(path string) (*ItemCatalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read item catalog: %w", err)
+	}
+
+	catalog, err := ParseItemCatalog(data)
+	if err != nil {
+		return nil, fmt.Errorf("item catalog %s: %w", path, err)
+	}
+	return catalog, nil
+}
+
+// ParseItemCatalog decodes and validates an item catalog
+func ParseItemCatalog(data []byte) (*ItemCatalog, error) {
+	catalog := &ItemCatalog{}
+	if err := decodeCatalog(data, catalog); err != nil {
+		return nil, err
+	}
+	if err := catalog.Validate(); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+// Validate reports every problem in the item catalog at once
+func (c *ItemCatalog) Validate() error {
+	var errs []error
+	seen := make(map[string]bool, len(c.Items))
+	for _, item := range c.Items {
+		errs = append(errs, item.validate()...)
+		if seen[item.ID] {
+			errs = append(errs, fmt.Errorf("item %q: duplicate id", item.ID))
+		}
+		seen[item.ID] = true
+	}
+	return errors.Join(errs...)
+}
+
+// templates indexes the items by id, a nil catalog has no items
+func (c *ItemCatalog) templates() map[string]*ItemTemplate {
+	if c == nil {
+		return nil
+	}
+	items := make(map[string]*ItemTemplate, len(c.Items))
+	for _, item := range c.Items {
+		items[item.ID] = item
+	}
+	return items
+}
+
+// decodeCatalog decodes a catalog file, unknown fields are most likely typos so they fail
+func decodeCatalog(data []byte, catalog any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(catalog); err != nil {
+		return fmt.Errorf("invalid json: %w", err)
+	}
+	return nil
+}
+
+// LoadEnemyCatalog reads, validates the catalog file and loads every archetype image.
+// Loot tables can only drop the items of the item catalog
+func LoadEnemyCatalog(path string, items *ItemCatalog) (*EnemyCatalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read enemy catalog: %w", err)
+	}
+
+	catalog, err := ParseEnemyCatalog(data, items)
+	if err != nil {
+		return nil, fmt.Errorf("enemy catalog %s: %w", path, err)
+	}
+
+	var wg sync.WaitGroup
+	for _, archetype := range catalog.Archetypes {
+		if archetype.Image == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(a *EnemyArchetype) {
+			defer wg.Done()
+			a.imageBytes = LoadAndProcessImage(a.Image, enemyImageSize, enemyImageSize)
+		}(archetype)
+	}
+	wg.Wait()
+
+	return catalog, nil
+}
+
+// ParseEnemyCatalog decodes and validates a catalog without touching image files, items may be nil
+func ParseEnemyCatalog(data []byte, items *ItemCatalog) (*EnemyCatalog, error) {
+	catalog := &EnemyCatalog{}
+	if err := decodeCatalog(data, catalog); err != nil {
+		return nil, err
+	}
+	if err := catalog.Validate(items); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+// Validate reports every problem in the catalog at once, loot is checked against the items
+func (c *EnemyCatalog) Validate(itemCatalog *ItemCatalog) error {
+	if len(c.Archetypes) == 0 {
+		return errors.New("catalog has no archetypes")
+	}
+
+	var errs []error
+	items := itemCatalog.templates()
+	seen := make(map[string]bool)
+	for i, a := range c.Archetypes {
+		fail := func(format string, args ...any) {
+			errs = append(errs, fmt.Errorf("archetype #%d (%q): %s", i+1, a.ID, fmt.Sprintf(format, args...)))
+		}
+
+		switch {
+		case a.ID == "":
+			fail("id is required")
+		case seen[a.ID]:
+			fail("duplicate id")
+		}
+		seen[a.ID] = true
+
+		if a.Name == "" {
+			fail("name is required")
+		}
+		if a.MinLevel < 1 {
+			fail("min_level must be at least 1, got %d", a.MinLevel)
+		}
+		if a.MaxLevel != 0 && a.MaxLevel < a.MinLevel {
+			fail("max_level %d is below min_level %d", a.MaxLevel, a.MinLevel)
+		}
+		if a.Weight <= 0 {
+			fail("weight must be positive, got %d", a.Weight)
+		}
+		if a.BaseHp <= 0 {
+			fail("base_hp must be positive, got %g", a.BaseHp)
+		}
+		if a.HpGrowth < 1 {
+			fail("hp_growth must be at least 1, got %g", a.HpGrowth)
+		}
+		if a.ArmorPerLevel < 0 || a.HpRegenPerStage < 0 {
+			fail("armor_per_level and hp_regen_per_stage can not be negative")
+		}
+		if a.GoldMultiplier <= 0 || a.ExpMultiplier <= 0 {
+			fail("gold_multiplier and exp_multiplier must be positive")
+		}
+		if a.Image != "" {
+			if _, err := os.Stat(a.Image); err != nil {
+				fail("image %s: %v", a.Image, err)
+			}
+		}
+
+		a.resistances = make(map[pb.DamageType]float64, len(a.Resistances))
+		for name, value := range a.Resistances {
+			damageType, ok := parseEnumName[pb.DamageType](pb.DamageType_value, "DAMAGE_TYPE_", name)
+			if !ok {
+				fail("unknown damage type %q in resistances", name)
+				continue
+			}
+			if value > 1 || value < MinResistance {
+				fail("resistance to %s must be between %g and 1, got %g", name, MinResistance, value)
+			}
+			a.resistances[damageType] = value
+		}
+
+		if a.Loot != nil {
+			for _, problem := range a.Loot.validate(items) {
+				fail("%s", problem)
+			}
+		}
+	}
+
+	if err := c.checkLevelCoverage(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// checkLevelCoverage makes sure the spawner finds an archetype for every level
+func (c *EnemyCatalog) checkLevelCoverage() error {
+	ranges := make([]*EnemyArchetype, 0, len(c.Archetypes))
+	for _, a := range c.Archetypes {
+		if a.MinLevel >= 1 && (a.MaxLevel == 0 || a.MaxLevel >= a.MinLevel) {
+			ranges = append(ranges, a)
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].MinLevel < ranges[j].MinLevel })
+
+	var covered int64 // every level up to this one has an archetype
+	for _, a := range ranges {
+		if a.MinLevel > covered+1 {
+			break
+		}
+		if a.MaxLevel == 0 {
+			return nil
+		}
+		covered = max(covered, a.MaxLevel)
+	}
+	return fmt.Errorf("no archetype covers level %d", covered+1)
+}
+
+// ForLevel picks a weighted random archetype that can appear at the level, rng may be nil
+func (c *EnemyCatalog) ForLevel(level int64, rng *rand.Rand) *EnemyArchetype {
+	var candidates []*EnemyArchetype
+	totalWeight := 0
+	for _, a := range c.Archetypes {
+		if level >= a.MinLevel && (a.MaxLevel == 0 || level <= a.MaxLevel) {
+			candidates = append(candidates, a)
+			totalWeight += a.Weight
+		}
+	}
+	if len(candidates) == 0 {
+		log.Printf("No enemy archetype for level %d, using the first one", level)
+		return c.Archetypes[0]
+	}
+
+	var roll int
+	if rng != nil {
+		roll = rng.IntN(totalWeight)
+	} else {
+		roll = rand.IntN(totalWeight)
+	}
+	for _, a := range candidates {
+		if roll < a.Weight {
+			return a
+		}
+		roll -= a.Weight
+	}
+	return candidates[len(candidates)-1]
+}
+
+func (a *EnemyArchetype) Stats(level int64, stage int64) EnemyStats {
+	hp := a.BaseHp * math.Pow(a.HpGrowth, float64(level-1))
+	return EnemyStats{
+		EnemyMaxHp:     hp,
+		EnemyLevel:     level,
+		Armor:          a.ArmorPerLevel * float64(level-1),
+		HpRegen:        hp * a.HpRegenPerStage * float64(stage-1),
+		Resistances:    a.resistances,
+		GoldMultiplier: a.GoldMultiplier,
+		ExpMultiplier:  a.ExpMultiplier,
+		Loot:           a.Loot,
+	}
+}
+
+// NewEnemy creates a fresh enemy of this archetype
+func (a *EnemyArchetype) NewEnemy(level int64, stage int64) *Enemy {
+	stats := a.Stats(level, stage)
+	return &Enemy{
+		ID:             GenerateID(),
+		Name:           fmt.Sprintf("Level %d %s", level, a.Name),
+		MaxHealth:      stats.EnemyMaxHp,
+		CurrentHealth:  stats.EnemyMaxHp,
+		Level:          level,
+		Stage:          stage,
+		Armor:          stats.Armor,
+		HpRegen:        stats.HpRegen,
+		Resistances:    stats.Resistances,
+		GoldMultiplier: stats.GoldMultiplier,
+		ExpMultiplier:  stats.ExpMultiplier,
+		Loot:           stats.Loot,
+		Image:          a.imageBytes,
+	}
+}
@@ -0,0 +1,84 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShippedEnemyCatalogIsValid(t *testing.T) {
+	t.Chdir("../..")
+	items, err := LoadItemCatalog("static/items.json")
+	require.NoError(t, err)
+	catalog, err := LoadEnemyCatalog("static/enemies.json", items)
+	require.NoError(t, err)
+
+	for _, archetype := range catalog.Archetypes {
+		assert.NotEmpty(t, archetype.imageBytes, "image of %s should be loaded", archetype.ID)
+	}
+}
+
+func TestParseEnemyCatalog(t *testing.T) {
+	catalog, err := ParseEnemyCatalog([]byte(`{"archetypes": [
+		{"id": "rat", "name": "Rat", "min_level": 1, "max_level": 3, "weight": 1, "base_hp": 10, "hp_growth": 1.5,
+		 "gold_multiplier": 0.5, "exp_multiplier": 1},
+		{"id": "imp", "name": "Imp", "min_level": 4, "weight": 1, "base_hp": 50, "hp_growth": 1, "armor_per_level": 1,
+		 "resistances": {"fire": 0.9}, "gold_multiplier": 2, "exp_multiplier": 2}
+	]}`), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "rat", catalog.ForLevel(3, nil).ID)
+	imp := catalog.ForLevel(4, nil)
+	assert.Equal(t, "imp", imp.ID)
+
+	enemy := imp.NewEnemy(5, 1)
+	assert.Equal(t, "Level 5 Imp", enemy.Name)
+	assert.Equal(t, 50.0, enemy.MaxHealth)
+	assert.Equal(t, 4.0, enemy.Armor)
+	assert.Equal(t, 0.9, enemy.Resistances[pb.DamageType_DAMAGE_TYPE_FIRE])
+	assert.Equal(t, 2.0, enemy.GoldMultiplier)
+
+	assert.Equal(t, 10*1.5*1.5, catalog.ForLevel(3, nil).Stats(3, 1).EnemyMaxHp)
+}
+
+func TestParseEnemyCatalogReportsProblems(t *testing.T) {
+	_, err := ParseEnemyCatalog([]byte(`{"archetypes": [
+		{"id": "rat", "name": "", "min_level": 2, "max_level": 3, "weight": 0, "base_hp": 10, "hp_growth": 1,
+		 "resistances": {"lightning": 0.5}, "gold_multiplier": 1, "exp_multiplier": 1},
+		{"id": "rat", "name": "Other rat", "min_level": 1, "max_level": 4, "weight": 1, "base_hp": -1, "hp_growth": 0.5,
+		 "image": "missing.webp", "resistances": {"fire": -10}, "gold_multiplier": 1, "exp_multiplier": 1}
+	]}`), nil)
+	require.Error(t, err)
+
+	for _, problem := range []string{
+		`archetype #1 ("rat"): name is required`,
+		`archetype #1 ("rat"): weight must be positive`,
+		`unknown damage type "lightning"`,
+		`archetype #2 ("rat"): duplicate id`,
+		`base_hp must be positive`,
+		`hp_growth must be at least 1`,
+		`image missing.webp`,
+		`resistance to fire must be between -1 and 1, got -10`,
+		`no archetype covers level 5`,
+	} {
+		assert.Contains(t, err.Error(), problem)
+	}
+
+	_, err = ParseEnemyCatalog([]byte(`{"archetypes": [{"id": "rat", "hp": 5}]}`), nil)
+	assert.ErrorContains(t, err, `unknown field "hp"`)
+}
+
+func TestParseItemCatalogReportsProblems(t *testing.T) {
+	_, err := ParseItemCatalog([]byte(`{"items": [
+		{"id": "club", "name": "Club", "slot": "weapon", "base_damage": 10},
+		{"id": "club", "name": "Other club", "slot": "weapon", "base_damage": 10}
+	]}`))
+	assert.ErrorContains(t, err, `item "club": duplicate id`)
+
+	_, err = ParseEnemyCatalog([]byte(`{"archetypes": [{"id": "rat", "name": "Rat", "min_level": 1, "weight": 1,
+		"base_hp": 10, "hp_growth": 1, "gold_multiplier": 1, "exp_multiplier": 1,
+		"loot": {"drop_chance": 1, "entries": [{"item": "club", "weight": 1}]}}]}`), nil)
+	assert.ErrorContains(t, err, `loot references unknown item "club"`, "loot can only drop items of the item catalog")
+}
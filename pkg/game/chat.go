@@ -0,0 +1,107 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxChatMessageLength = 200 // in runes
+	ChatHistorySize      = 50
+)
+
+var (
+	ErrEmptyMessage    = errors.New("message is empty")
+	ErrMessageTooLong  = errors.New("message is too long")
+	ErrMessageRejected = errors.New("message rejected by the chat filter")
+)
+
+// ChatFilter checks a chat message before it is sent, it may rewrite the text.
+// A filter returning an error drops the message
+type ChatFilter func(player *pb.Player, text string) (string, error)
+
+// BannedWordsFilter masks every listed word with asterisks, case insensitive
+func BannedWordsFilter(words ...string) ChatFilter {
+	banned := make([][]rune, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			banned = append(banned, lowerRunes([]rune(word)))
+		}
+	}
+	return func(_ *pb.Player, text string) (string, error) {
+		// compare rune by rune so the masked positions line up with the original text
+		runes := []rune(text)
+		lower := lowerRunes(runes)
+		for _, word := range banned {
+			for i := 0; i+len(word) <= len(lower); i++ {
+				if slices.Equal(lower[i:i+len(word)], word) {
+					for j := i; j < i+len(word); j++ {
+						runes[j] = '*'
+					}
+				}
+			}
+		}
+		return string(runes), nil
+	}
+}
+
+func lowerRunes(runes []rune) []rune {
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	return lower
+}
+
+// SendChat sends the message to everybody in the game and keeps it in the chat history
+func (g *Game) SendChat(playerID string, text string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	session, ok := g.Players[playerID]
+	if !ok {
+		return ErrPlayerNotFound
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ErrEmptyMessage
+	}
+	if n := utf8.RuneCountInString(text); n > MaxChatMessageLength {
+		return fmt.Errorf("%w: %d of %d characters", ErrMessageTooLong, n, MaxChatMessageLength)
+	}
+	if g.ChatFilter != nil {
+		filtered, err := g.ChatFilter(session.Data, text)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrMessageRejected, err)
+		}
+		text = filtered
+	}
+
+	msg := &pb.ChatMessage{
+		PlayerId:   playerID,
+		PlayerName: session.Data.GetName(),
+		Text:       text,
+		SentAt:     g.now().Unix(),
+	}
+	g.chatHistory = append(g.chatHistory, msg)
+	if len(g.chatHistory) > ChatHistorySize {
+		g.chatHistory = g.chatHistory[len(g.chatHistory)-ChatHistorySize:]
+	}
+
+	g.broadcastToAll(&pb.ServerToClient{Event: &pb.ServerToClient_Chat{Chat: msg}})
+	return nil
+}
+
+// ChatHistory returns the last ChatHistorySize messages, oldest first
+func (g *Game) ChatHistory() []*pb.ChatMessage {
+	g.Lock()
+	defer g.Unlock()
+
+	return append([]*pb.ChatMessage(nil), g.chatHistory...)
+}
@@ -0,0 +1,63 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendChat(t *testing.T) {
+	game := NewGame()
+	alice := InitializePlayer("Alice")
+	bob := InitializePlayer("Bob")
+	aliceUpdates := NewOutbox(nil)
+	bobUpdates := NewOutbox(nil)
+	game.AddPlayer(alice, aliceUpdates)
+	game.AddPlayer(bob, bobUpdates)
+
+	require.NoError(t, game.SendChat(alice.GetId(), "  привет  "))
+	for _, updates := range []*Outbox{aliceUpdates, bobUpdates} {
+		msg := updates.Pop().GetChat()
+		require.NotNil(t, msg, "the sender sees his message too")
+		assert.Equal(t, "привет", msg.GetText())
+		assert.Equal(t, "Alice", msg.GetPlayerName())
+	}
+
+	assert.ErrorIs(t, game.SendChat(alice.GetId(), "   "), ErrEmptyMessage)
+	assert.ErrorIs(t, game.SendChat(alice.GetId(), strings.Repeat("я", MaxChatMessageLength+1)), ErrMessageTooLong)
+	assert.NoError(t, game.SendChat(alice.GetId(), strings.Repeat("я", MaxChatMessageLength)), "the limit counts letters, not bytes")
+	assert.ErrorIs(t, game.SendChat("nobody", "hi"), ErrPlayerNotFound)
+}
+
+func TestChatHistoryIsBounded(t *testing.T) {
+	game := NewGame()
+	player := InitializePlayer("Alice")
+	game.AddPlayer(player, NewOutbox(nil))
+
+	for i := range ChatHistorySize + 5 {
+		require.NoError(t, game.SendChat(player.GetId(), fmt.Sprint(i)))
+	}
+	history := game.ChatHistory()
+	require.Len(t, history, ChatHistorySize)
+	assert.Equal(t, "5", history[0].GetText())
+	assert.Equal(t, fmt.Sprint(ChatHistorySize+4), history[len(history)-1].GetText())
+}
+
+func TestChatFilter(t *testing.T) {
+	game := NewGame()
+	player := InitializePlayer("Alice")
+	game.AddPlayer(player, NewOutbox(nil))
+
+	game.ChatFilter = BannedWordsFilter("гоблин")
+	require.NoError(t, game.SendChat(player.GetId(), "Злой ГОБЛИН тут"))
+	assert.Equal(t, "Злой ****** тут", game.ChatHistory()[0].GetText())
+
+	game.ChatFilter = func(*pb.Player, string) (string, error) { return "", errors.New("muted") }
+	assert.ErrorIs(t, game.SendChat(player.GetId(), "hi"), ErrMessageRejected)
+	assert.Len(t, game.ChatHistory(), 1)
+}
@@ -0,0 +1,118 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+)
+
+var ErrUnknownCompanion = errors.New("unknown companion")
+
+// CompanionType is a kind of helper players can hire for gold
+type CompanionType struct {
+	ID         string
+	Name       string
+	DPS        float64
+	DamageType pb.DamageType
+	// the first one costs BaseCost, every next one CostGrowth times more
+	BaseCost   int64
+	CostGrowth float64
+}
+
+var CompanionTypes = []CompanionType{
+	{ID: "squire", Name: "Оруженосец", DPS: 1, DamageType: pb.DamageType_DAMAGE_TYPE_PHYSICAL, BaseCost: 15, CostGrowth: 1.15},
+	{ID: "archer", Name: "Лучник", DPS: 5, DamageType: pb.DamageType_DAMAGE_TYPE_PHYSICAL, BaseCost: 100, CostGrowth: 1.15},
+	{ID: "pyromancer", Name: "Пиромант", DPS: 20, DamageType: pb.DamageType_DAMAGE_TYPE_FIRE, BaseCost: 500, CostGrowth: 1.17},
+	{ID: "alchemist", Name: "Алхимик", DPS: 60, DamageType: pb.DamageType_DAMAGE_TYPE_POISON, BaseCost: 2000, CostGrowth: 1.2},
+}
+
+func companionType(id string) (*CompanionType, bool) {
+	for i := range CompanionTypes {
+		if CompanionTypes[i].ID == id {
+			return &CompanionTypes[i], true
+		}
+	}
+	return nil, false
+}
+
+// HireCost is the price of the next companion of this type for a player who already has owned of them
+func (c *CompanionType) HireCost(owned int64) int64 {
+	return int64(float64(c.BaseCost) * math.Pow(c.CostGrowth, float64(owned)))
+}
+
+func playerCompanion(player *pb.Player, id string) *pb.Companion {
+	for _, companion := range player.GetCompanions() {
+		if companion.GetCompanionId() == id {
+			return companion
+		}
+	}
+	return nil
+}
+
+// HireCompanion buys one more companion of the type for the player
+func (g *Game) HireCompanion(playerID string, companionID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	session, ok := g.Players[playerID]
+	if !ok {
+		return ErrPlayerNotFound
+	}
+	kind, ok := companionType(companionID)
+	if !ok {
+		return ErrUnknownCompanion
+	}
+
+	player := session.Data
+	companion := playerCompanion(player, companionID)
+	cost := kind.HireCost(companion.GetCount())
+	if player.GetResources().GetGold() < cost {
+		return fmt.Errorf("%w: %s costs %d", ErrNotEnoughGold, kind.Name, cost)
+	}
+
+	if companion == nil {
+		companion = &pb.Companion{CompanionId: kind.ID}
+		player.Companions = append(player.Companions, companion)
+	}
+	player.Resources.Gold -= cost
+	companion.Count++
+	// name and dps are refreshed from the type so balance changes reach old players too
+	companion.Name = kind.Name
+	companion.Dps = kind.DPS * float64(companion.Count)
+	companion.DamageType = kind.DamageType
+
+	log.Printf("Player %s hired %s for %d gold, has %d now", player.GetName(), kind.Name, cost, companion.Count)
+	g.sendPlayerState(player)
+	return nil
+}
+
+// attackWithCompanions lets the companions of every connected player hit the first active enemy
+func (g *Game) attackWithCompanions(seconds float64) {
+	if len(g.activeEnemies()) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(g.Players))
+	for id, session := range g.Players {
+		if session.connected && len(session.Data.GetCompanions()) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		for _, companion := range g.Players[id].Data.GetCompanions() {
+			hit := DamageRoll{Amount: companion.GetDps() * seconds, Type: companion.GetDamageType()}
+			if hit.Amount <= 0 {
+				continue
+			}
+			if err := g.applyDamage("", hit, id); err != nil {
+				// nothing to hit until the spawner brings more enemies
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHireCompanion(t *testing.T) {
+	game := NewGame()
+	player := InitializePlayer("Tester")
+	game.AddPlayer(player, NewOutbox(nil))
+	squire, _ := companionType("squire")
+
+	player.Resources.Gold = squire.HireCost(0) + squire.HireCost(1)
+	require.NoError(t, game.HireCompanion(player.GetId(), "squire"))
+	require.NoError(t, game.HireCompanion(player.GetId(), "squire"))
+	assert.Zero(t, player.GetResources().GetGold())
+	require.Len(t, player.GetCompanions(), 1)
+	assert.Equal(t, int64(2), player.GetCompanions()[0].GetCount())
+	assert.Equal(t, 2*squire.DPS, player.GetCompanions()[0].GetDps())
+
+	assert.ErrorIs(t, game.HireCompanion(player.GetId(), "squire"), ErrNotEnoughGold)
+	assert.ErrorIs(t, game.HireCompanion(player.GetId(), "dragon"), ErrUnknownCompanion)
+}
+
+func TestCompanionsAttackOnTick(t *testing.T) {
+	game := NewGame()
+	player := InitializePlayer("Tester")
+	player.Companions = []*pb.Companion{{CompanionId: "squire", Count: 1, Dps: 10}}
+	game.AddPlayer(player, NewOutbox(nil))
+	enemy := game.CreateEnemy(EnemyStats{EnemyMaxHp: 100, EnemyLevel: 1}, "Troll", nil)
+
+	game.Tick(3 * time.Second)
+	assert.Equal(t, 70.0, enemy.CurrentHealth)
+	assert.Equal(t, 30.0, enemy.damageDealt[player.GetId()], "companion damage counts as the player contribution")
+
+	game.DetachPlayer(player.GetId(), game.Players[player.GetId()].Updates)
+	game.Tick(3 * time.Second)
+	assert.Equal(t, 70.0, enemy.CurrentHealth, "companions of disconnected players rest")
+}
@@ -0,0 +1,113 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"math"
+	"math/rand/v2"
+)
+
+const (
+	// armor equal to this value halves incoming damage
+	ArmorMitigationFactor = 100.0
+	// no enemy can ignore more than this fraction of a damage type
+	MaxResistance = 0.75
+	// a weakness can at most double the damage taken
+	MinResistance = -1.0
+
+	BaseCritChance     = 0.05
+	MaxCritChance      = 0.75
+	BaseCritMultiplier = 2.0 // the same for everyone, only the chance to crit grows
+	// every player level above the first adds this fraction to click damage
+	LevelDamageBonus = 0.02
+
+	// defaults of the built-in goblin, catalog archetypes set their own
+	ArmorPerLevel = 2.0
+	// fraction of max hp regenerated per second for every stage after the first
+	HpRegenPerStage = 0.002
+)
+
+// MitigateDamage runs raw damage through the enemy defences: armor first, then the resistance to its type
+func (e *Enemy) MitigateDamage(raw float64, damageType pb.DamageType) float64 {
+	damage := raw
+	if e.Armor > 0 {
+		damage *= ArmorMitigationFactor / (ArmorMitigationFactor + e.Armor)
+	}
+
+	resistance := math.Max(math.Min(e.Resistances[damageType], MaxResistance), MinResistance)
+	damage *= 1 - resistance
+
+	return math.Max(damage, 0)
+}
+
+// regenerateEnemies heals active enemies for the elapsed seconds and tells everyone their new hp
+func (g *Game) regenerateEnemies(seconds float64) {
+	for _, enemy := range g.activeEnemies() {
+		if enemy.HpRegen <= 0 || enemy.CurrentHealth >= enemy.MaxHealth {
+			continue
+		}
+
+		enemy.CurrentHealth = math.Min(enemy.CurrentHealth+enemy.HpRegen*seconds, enemy.MaxHealth)
+		g.broadcastToAll(&pb.ServerToClient{
+			Event: &pb.ServerToClient_GameStateUpdate{
+				GameStateUpdate: &pb.GameStateUpdate{
+					EnemyId:        enemy.ID,
+					EnemyCurrentHp: enemy.CurrentHealth,
+					BossTimeLeft:   enemy.TimeLeft,
+				},
+			},
+		})
+	}
+}
+
+// DamageRoll is one hit before the enemy defences
+type DamageRoll struct {
+	Amount   float64
+	Type     pb.DamageType
+	Critical bool
+}
+
+// PlayerDamage is the raw damage of one non critical click with the current weapon, gear, level and buffs.
+// The client shows this number, the server rolls hits from it in RollDamage
+func PlayerDamage(player *pb.Player) float64 {
+	weapon := player.GetEquipment().GetWeapon()
+	damage := float64(weapon.GetBaseDamage() + weapon.GetDamageGrowth()*float32(weapon.GetLevel()-1))
+	for _, item := range equippedItems(player) {
+		damage += float64(item.GetBonusDamage())
+	}
+	levelBonus := 1 + LevelDamageBonus*float64(max(player.GetStats().GetLevel()-1, 0))
+	strengthBonus := 1 + StrengthDamageBonus*float64(player.GetStats().GetAttributes().GetStrength())
+	buffBonus := 1 + BuffValue(player, pb.BuffType_BUFF_TYPE_DAMAGE)
+	guildBonus := 1 + player.GetGuildDamageBonus()
+	return damage * levelBonus * strengthBonus * buffBonus * guildBonus * SoulDamageMultiplier(player)
+}
+
+// PlayerCritChance is the chance of a click to be critical
+func PlayerCritChance(player *pb.Player) float64 {
+	chance := BaseCritChance + PrecisionCritChance*float64(player.GetStats().GetAttributes().GetPrecision())
+	for _, item := range equippedItems(player) {
+		chance += float64(item.GetCritChance())
+	}
+	return math.Min(chance, MaxCritChance)
+}
+
+// RollDamage rolls one click of the player
+func RollDamage(player *pb.Player, rng *rand.Rand) DamageRoll {
+	hit := DamageRoll{
+		Amount: PlayerDamage(player),
+		Type:   player.GetEquipment().GetWeapon().GetDamageType(),
+	}
+	if rng.Float64() < PlayerCritChance(player) {
+		hit.Critical = true
+		hit.Amount *= BaseCritMultiplier
+	}
+	return hit
+}
+
+// PlayerGoldFind is the fraction of extra kill gold the player gets from gear
+func PlayerGoldFind(player *pb.Player) float64 {
+	goldFind := FortuneGoldFind*float64(player.GetStats().GetAttributes().GetFortune()) + BuffValue(player, pb.BuffType_BUFF_TYPE_GOLD_FIND)
+	for _, item := range equippedItems(player) {
+		goldFind += float64(item.GetGoldFind())
+	}
+	return goldFind
+}
@@ -0,0 +1,72 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMitigateDamage(t *testing.T) {
+	enemy := &Enemy{
+		Armor: ArmorMitigationFactor,
+		Resistances: map[pb.DamageType]float64{
+			pb.DamageType_DAMAGE_TYPE_FIRE:   0.5,
+			pb.DamageType_DAMAGE_TYPE_COLD:   2.0,
+			pb.DamageType_DAMAGE_TYPE_POISON: -0.5,
+		},
+	}
+
+	assert.InDelta(t, 50.0, enemy.MitigateDamage(100, pb.DamageType_DAMAGE_TYPE_PHYSICAL), 1e-9, "armor equal to the factor halves damage")
+	assert.InDelta(t, 25.0, enemy.MitigateDamage(100, pb.DamageType_DAMAGE_TYPE_FIRE), 1e-9)
+	assert.InDelta(t, 50.0*(1-MaxResistance), enemy.MitigateDamage(100, pb.DamageType_DAMAGE_TYPE_COLD), 1e-9, "resistance is capped")
+	assert.InDelta(t, 75.0, enemy.MitigateDamage(100, pb.DamageType_DAMAGE_TYPE_POISON), 1e-9, "weakness increases damage")
+
+	fragile := &Enemy{Resistances: map[pb.DamageType]float64{pb.DamageType_DAMAGE_TYPE_FIRE: -10}}
+	assert.InDelta(t, 100.0*(1-MinResistance), fragile.MitigateDamage(100, pb.DamageType_DAMAGE_TYPE_FIRE), 1e-9, "weakness is capped too")
+}
+
+func TestEnemyRegeneration(t *testing.T) {
+	game := NewGame()
+	enemy := game.CreateEnemy(EnemyStats{EnemyMaxHp: 100, EnemyLevel: 1, HpRegen: 10}, "Troll", nil)
+	require.NoError(t, game.ApplyDamage(enemy.ID, 50, pb.DamageType_DAMAGE_TYPE_PHYSICAL, ""))
+
+	game.Tick(2 * time.Second)
+	assert.Equal(t, 70.0, enemy.CurrentHealth)
+
+	game.Tick(time.Minute)
+	assert.Equal(t, 100.0, enemy.CurrentHealth, "regeneration never goes above max hp")
+}
+
+func TestPlayerDamageScalesWithLevelAndGear(t *testing.T) {
+	player := InitializePlayer("Tester")
+	base := PlayerDamage(player)
+
+	player.Stats.Level = 11
+	assert.InDelta(t, base*(1+10*LevelDamageBonus), PlayerDamage(player), 1e-9)
+
+	player.Equipment.Trinket = &pb.Item{Slot: pb.ItemSlot_ITEM_SLOT_TRINKET, CritChance: 0.1}
+	assert.InDelta(t, BaseCritChance+0.1, PlayerCritChance(player), 1e-6)
+
+	player.Equipment.Trinket.CritChance = 5
+	assert.Equal(t, MaxCritChance, PlayerCritChance(player), "crit chance is capped")
+}
+
+func TestAttackRollsCrits(t *testing.T) {
+	game := NewGame()
+	game.SeedRandom(1)
+	player := InitializePlayer("Tester")
+	player.Equipment.Trinket = &pb.Item{Slot: pb.ItemSlot_ITEM_SLOT_TRINKET, CritChance: 1}
+	updates := NewOutbox(nil)
+	game.AddPlayer(player, updates)
+
+	enemy := game.CreateEnemy(EnemyStats{EnemyMaxHp: 1000, EnemyLevel: 1}, "Troll", nil)
+	require.NoError(t, game.Attack(enemy.ID, player.GetId()))
+
+	hit := updates.Pop().GetGameStateUpdate().GetLastHit()
+	assert.True(t, hit.GetCritical())
+	assert.InDelta(t, PlayerDamage(player)*BaseCritMultiplier, hit.GetDamageDealt(), 1e-9)
+	assert.ErrorIs(t, game.Attack(enemy.ID, "nobody"), ErrPlayerNotFound)
+}
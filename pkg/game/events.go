@@ -0,0 +1,64 @@
+package game
+
+// EventKind is something that happened in the game worth reacting to
+type EventKind int
+
+const (
+	EventClick EventKind = iota + 1
+	EventKill
+	EventGoldEarned
+	EventWeaponUpgraded
+	// Amount is the new level, not a delta
+	EventLevelReached
+	EventDamageDealt
+)
+
+var eventKindNames = map[EventKind]string{
+	EventClick:          "clicks",
+	EventKill:           "kills",
+	EventGoldEarned:     "gold_earned",
+	EventWeaponUpgraded: "weapon_upgrades",
+	EventLevelReached:   "level",
+	EventDamageDealt:    "damage_dealt",
+}
+
+func (k EventKind) String() string {
+	return eventKindNames[k]
+}
+
+// GameEvent is published by the game every time a player does something that counts
+type GameEvent struct {
+	Kind     EventKind
+	PlayerID string
+	Amount   int64
+}
+
+// EventHandler runs synchronously under the game lock, so it must only use the unlocked helpers of Game
+type EventHandler func(event GameEvent)
+
+// EventBus delivers internal game events to every subscriber in the order they subscribed.
+// Not safe for concurrent use, Game publishes under its lock
+type EventBus struct {
+	handlers []EventHandler
+}
+
+func (b *EventBus) Subscribe(handler EventHandler) {
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *EventBus) Publish(event GameEvent) {
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+}
+
+// Subscribe adds a handler for the internal events of the game
+func (g *Game) Subscribe(handler EventHandler) {
+	g.Lock()
+	defer g.Unlock()
+	g.events.Subscribe(handler)
+}
+
+func (g *Game) publish(kind EventKind, playerID string, amount int64) {
+	g.events.Publish(GameEvent{Kind: kind, PlayerID: playerID, Amount: amount})
+}
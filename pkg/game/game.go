@@ -4,43 +4,101 @@ package game
 import (
 	"bytes"
 	pb "clicker/gen/proto"
+	"errors"
 	"fmt"
 	"image/png"
 	"log"
 	"math"
+	"math/rand/v2"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/nfnt/resize"
 	"golang.org/x/image/webp"
+	"google.golang.org/protobuf/proto"
 )
 
 type EnemyStats struct {
-	EnemyMaxHp float64
-	EnemyLevel int64
-	// TODO: add armor, hp regen etc. in future
+	EnemyMaxHp  float64
+	EnemyLevel  int64
+	Armor       float64
+	HpRegen     float64 // per second
+	Resistances map[pb.DamageType]float64
+	// scale kill rewards, 0 is treated as 1
+	GoldMultiplier float64
+	ExpMultiplier  float64
+	Loot           *LootTable
 }
 
 type Game struct {
 	sync.Mutex
 	LastEnemyID string
-	Enemies     []*Enemy
-	Players     map[string]*PlayerSession // player id -> his session
+	// the first ActiveEnemies of them can be attacked, the rest wait in line
+	Enemies       []*Enemy
+	ActiveEnemies int
+	// generates new enemies when the old ones die, nil means only Enemies are fought
+	Spawner *Spawner
+	Players map[string]*PlayerSession // player id -> his session
+
+	LootPolicy   LootPolicy
+	RewardPolicy RewardPolicy
+	Shop         []*ShopOffer
+	// checked on every internal event, DefaultAchievements unless replaced
+	Achievements []*Achievement
+	Quests       QuestSchedule
+	// checks chat messages before they are sent, nil lets everything through
+	ChatFilter ChatFilter
+	// shared by every room, nil turns guilds off
+	Guilds *GuildRegistry
+	// records completed trades, nil only logs them
+	TradeAudit TradeAudit
+	// what time it is for the game, time.Now if nil
+	Clock    func() time.Time
+	events   EventBus
+	lootTurn int
+	rng      *rand.Rand
+	// last ChatHistorySize messages, oldest first
+	chatHistory []*pb.ChatMessage
+	trades      map[string]*Trade
 }
 
 type PlayerSession struct {
 	Data    *pb.Player
-	Updates chan *pb.ServerToClient
+	Updates *Outbox
+	Token   string
+
+	// false while the player lost his stream and may still resume
+	connected bool
+	resumed   chan struct{}
+	// when the stream was lost, the detached player is offline since then
+	detachedAt time.Time
+	seq        int64
+	history    []*pb.ServerToClient
+	// event totals since the player joined, for the session leaderboard
+	sessionStats map[EventKind]int64
 }
 
 type Enemy struct {
-	ID            string
-	Name          string
-	MaxHealth     float64
-	CurrentHealth float64
-	Level         int64
-	Image         []byte
+	ID             string
+	Name           string
+	MaxHealth      float64
+	CurrentHealth  float64
+	Level          int64
+	Stage          int64
+	Armor          float64
+	HpRegen        float64
+	Resistances    map[pb.DamageType]float64
+	GoldMultiplier float64
+	ExpMultiplier  float64
+	Loot           *LootTable
+	Image          []byte
+	Boss           bool
+	// seconds the room has left to kill the boss
+	TimeLeft float64
+	// player id -> damage that player dealt to this enemy
+	damageDealt map[string]float64
 	// some fine grained mutex for future generations, maybe
 	// sync.Mutex
 }
@@ -56,71 +114,200 @@ const (
 
 	WeaponUpgradeBaseCost       = 50
 	WeaponUpgradeCostMultiplier = 1.8
+
+	SessionGracePeriod = 30 * time.Second
+	SessionHistorySize = 100
+	// profiles of the players in the game are saved this often, a crash loses at most this much
+	SessionSaveInterval = time.Minute
+
+	DefaultActiveEnemies = 3
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrEnemyNotFound   = errors.New("enemy not found")
+	ErrNotEnoughGold   = errors.New("not enough gold")
 )
 
-func (g *Game) AddPlayer(player *pb.Player, updateChan chan *pb.ServerToClient) {
+// AddPlayer registers a new session and returns the token the client can resume it with
+func (g *Game) AddPlayer(player *pb.Player, updates *Outbox) string {
 	g.Lock()
 	defer g.Unlock()
 	if g.Players == nil {
 		g.Players = make(map[string]*PlayerSession)
 	}
-	g.Players[player.GetId()] = &PlayerSession{
-		Data:    player,
-		Updates: updateChan,
+	session := &PlayerSession{
+		Data:      player,
+		Updates:   updates,
+		Token:     GenerateID(),
+		connected: true,
 	}
+	g.refreshQuests(player)
+	g.syncGuildBonus(player)
+	g.Players[player.GetId()] = session
+	return session.Token
 }
 
-func (g *Game) RemovePlayer(playerID string) {
+func (g *Game) HasPlayer(playerID string) bool {
 	g.Lock()
 	defer g.Unlock()
-	if session, ok := g.Players[playerID]; ok {
-		delete(g.Players, playerID)
-		close(session.Updates)
+	_, ok := g.Players[playerID]
+	return ok
+}
+
+// DetachPlayer keeps the session of a player whose stream is gone so he can resume it.
+// updates must be the outbox of that stream, otherwise the session was already
+// taken over by a newer stream and nil is returned.
+// The returned channel is closed once the session is resumed
+func (g *Game) DetachPlayer(playerID string, updates *Outbox) <-chan struct{} {
+	g.Lock()
+	defer g.Unlock()
+	session, ok := g.Players[playerID]
+	if !ok || !session.connected || session.Updates != updates {
+		return nil
+	}
+	session.connected = false
+	session.detachedAt = g.now()
+	session.resumed = make(chan struct{})
+	session.Updates.Close()
+	return session.resumed
+}
+
+// RemoveDetachedPlayer removes the player only if nobody resumed his session
+func (g *Game) RemoveDetachedPlayer(playerID string) bool {
+	g.Lock()
+	defer g.Unlock()
+	session, ok := g.Players[playerID]
+	if !ok || session.connected {
+		return false
+	}
+	g.cancelTradeOf(playerID)
+	delete(g.Players, playerID)
+	return true
+}
+
+// SessionToken returns the token of the session of the player, connected or waiting to be resumed
+func (g *Game) SessionToken(playerID string) (string, bool) {
+	g.Lock()
+	defer g.Unlock()
+	session, ok := g.Players[playerID]
+	if !ok {
+		return "", false
+	}
+	return session.Token, true
+}
+
+// ResumeSession attaches a new stream to an existing session.
+// It returns the messages the client missed after lastSeq, complete is false
+// when some of them are no longer kept and the client needs a fresh state
+func (g *Game) ResumeSession(token string, lastSeq int64, updates *Outbox) (player *pb.Player, missed []*pb.ServerToClient, complete bool, err error) {
+	g.Lock()
+	defer g.Unlock()
+
+	var session *PlayerSession
+	for _, s := range g.Players {
+		if token != "" && s.Token == token {
+			session = s
+			break
+		}
+	}
+	if session == nil {
+		return nil, nil, false, ErrSessionNotFound
+	}
+
+	if session.connected {
+		// the old stream is not dead yet from our side, the new one wins
+		session.Updates.Close()
+	} else {
+		close(session.resumed)
+	}
+	session.connected = true
+	session.Updates = updates
+
+	if lastSeq <= 0 {
+		return session.Data, nil, false, nil
+	}
+	for _, msg := range session.history {
+		if msg.GetSeq() > lastSeq {
+			missed = append(missed, msg)
+		}
+	}
+	complete = lastSeq >= session.seq || (len(session.history) > 0 && session.history[0].GetSeq() <= lastSeq+1)
+	return session.Data, missed, complete, nil
+}
+
+// deliver numbers the message for this session, remembers it for replay and
+// pushes it to the stream if the player is connected
+func (g *Game) deliver(session *PlayerSession, msg *pb.ServerToClient) {
+	session.seq++
+	numbered := &pb.ServerToClient{
+		Event: msg.GetEvent(),
+		Seq:   session.seq,
+	}
+
+	session.history = append(session.history, numbered)
+	if len(session.history) > SessionHistorySize {
+		session.history[0] = nil
+		session.history = session.history[1:]
+	}
+
+	if !session.connected {
+		return
+	}
+	if err := session.Updates.Push(numbered); errors.Is(err, ErrSlowConsumer) {
+		// the stream notices the overflow and ends, the client resumes from the history
+		log.Printf("Player %s does not keep up with the updates, disconnecting", session.Data.GetId())
 	}
 }
 
 func (g *Game) broadcastToAll(msg *pb.ServerToClient) {
 	for _, session := range g.Players {
-		select {
-		case session.Updates <- msg:
-		default:
-			log.Printf("Player %s update channel is full. Message dropped", session.Data.GetId())
-		}
+		g.deliver(session, msg)
 	}
 }
 
 func (g *Game) Broadcast(msg *pb.ServerToClient, excludePlayerID string) {
+	g.Lock()
+	defer g.Unlock()
 	for id, session := range g.Players {
 		if id == excludePlayerID {
 			continue
 		}
-
-		select {
-		case session.Updates <- msg:
-		default:
-			log.Printf("Player %s update channel is full. Message dropped", session.Data.GetId())
-		}
+		g.deliver(session, msg)
 	}
 }
 
 func (g *Game) sendToPlayer(playerID string, msg *pb.ServerToClient) {
 	if session, ok := g.Players[playerID]; ok {
-		select {
-		case session.Updates <- msg:
-		default:
-			log.Printf("Player %s update channel is full. Message dropped", session.Data.GetId())
-		}
+		g.deliver(session, msg)
 	}
 }
 
+func (g *Game) SendToPlayer(playerID string, msg *pb.ServerToClient) {
+	g.Lock()
+	defer g.Unlock()
+	g.sendToPlayer(playerID, msg)
+}
+
 func (e *Enemy) ToProto() *pb.Enemy {
+	resistances := make([]*pb.Resistance, 0, len(e.Resistances))
+	for damageType, value := range e.Resistances {
+		resistances = append(resistances, &pb.Resistance{DamageType: damageType, Value: value})
+	}
+
 	return &pb.Enemy{
-		Id:        e.ID,
-		Name:      e.Name,
-		MaxHp:     e.MaxHealth,
-		CurrentHp: e.CurrentHealth,
-		Level:     e.Level,
-		Image:     e.Image,
+		Id:          e.ID,
+		Name:        e.Name,
+		MaxHp:       e.MaxHealth,
+		CurrentHp:   e.CurrentHealth,
+		Level:       e.Level,
+		Image:       e.Image,
+		Stage:       e.Stage,
+		Armor:       e.Armor,
+		HpRegen:     e.HpRegen,
+		Resistances: resistances,
+		Boss:        e.Boss,
+		TimeLeft:    e.TimeLeft,
 	}
 }
 
@@ -132,23 +319,28 @@ func InitializePlayer(name string) *pb.Player {
 			Level:        1,
 			Experience:   0,
 			NextLevelExp: 100,
+			Attributes:   &pb.Attributes{},
 		},
 		Resources: &pb.PlayerResources{
 			Gold: 2,
 		},
 		Equipment: &pb.PlayerEquipment{
-			Weapon: &pb.Weapon{
-				ItemId:       "starter_stick",
-				Name:         "Деревянная палка",
-				Level:        1,
-				BaseDamage:   5.0,
-				DamageGrowth: 2.0,
-			},
+			Weapon: starterWeapon(),
 		},
 	}
 	return player
 }
 
+func starterWeapon() *pb.Weapon {
+	return &pb.Weapon{
+		ItemId:       "starter_stick",
+		Name:         "Деревянная палка",
+		Level:        1,
+		BaseDamage:   5.0,
+		DamageGrowth: 2.0,
+	}
+}
+
 func (g *Game) GetCurrentEnemy() *Enemy {
 	g.Lock()
 	defer g.Unlock()
@@ -158,6 +350,33 @@ func (g *Game) GetCurrentEnemy() *Enemy {
 	return g.Enemies[0]
 }
 
+// GetActiveEnemies returns the enemies players can attack right now
+func (g *Game) GetActiveEnemies() []*Enemy {
+	g.Lock()
+	defer g.Unlock()
+	active := g.activeEnemies()
+	enemies := make([]*Enemy, len(active))
+	copy(enemies, active)
+	return enemies
+}
+
+func (g *Game) activeEnemies() []*Enemy {
+	if len(g.Enemies) <= g.ActiveEnemies {
+		return g.Enemies
+	}
+	return g.Enemies[:g.ActiveEnemies]
+}
+
+// findActiveEnemy resolves an attack target, empty id means the first active enemy
+func (g *Game) findActiveEnemy(enemyID string) (int, *Enemy) {
+	for i, enemy := range g.activeEnemies() {
+		if enemyID == "" || enemy.ID == enemyID {
+			return i, enemy
+		}
+	}
+	return -1, nil
+}
+
 func (g *Game) GetAllPlayers() []*pb.Player {
 	g.Lock()
 	defer g.Unlock()
@@ -168,32 +387,98 @@ func (g *Game) GetAllPlayers() []*pb.Player {
 	return players
 }
 
+// SnapshotPlayers copies the profile of everyone in the game, detached sessions included,
+// so they can be saved without holding the lock.
+// Goods in trade escrow are put back into the copies, a saved profile never loses them.
+// LastSeen of a copy is now for connected players and the disconnect for detached ones
+func (g *Game) SnapshotPlayers() []*pb.Player {
+	g.Lock()
+	defer g.Unlock()
+	players := make([]*pb.Player, 0, len(g.Players))
+	for id, session := range g.Players {
+		player := proto.Clone(session.Data).(*pb.Player)
+		player.LastSeen = g.now().Unix()
+		if !session.connected {
+			player.LastSeen = session.detachedAt.Unix()
+		}
+		gold, items := g.escrowOf(id)
+		player.Resources.Gold += gold
+		for _, item := range items {
+			player.Inventory = append(player.Inventory, proto.Clone(item).(*pb.Item))
+		}
+		players = append(players, player)
+	}
+	return players
+}
+
 func NewGame() *Game {
-	return &Game{
-		Enemies: make([]*Enemy, 0, 10),
-		Players: make(map[string]*PlayerSession),
+	g := &Game{
+		Enemies:       make([]*Enemy, 0, 10),
+		ActiveEnemies: DefaultActiveEnemies,
+		Players:       make(map[string]*PlayerSession),
+		trades:        make(map[string]*Trade),
+		Achievements:  DefaultAchievements,
+		Quests:        DefaultQuestSchedule,
+		rng:           rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0)),
 	}
+	g.events.Subscribe(g.trackAchievements)
+	g.events.Subscribe(g.trackQuests)
+	g.events.Subscribe(g.trackSessionStats)
+	return g
+}
+
+// SeedRandom makes every random roll of the game (loot, crits, spawned enemies...) repeatable
+func (g *Game) SeedRandom(seed uint64) {
+	g.Lock()
+	defer g.Unlock()
+	g.rng = rand.New(rand.NewPCG(seed, seed))
+}
+
+// ApplyDamage hits the enemy with raw damage of the given type, the enemy armor
+// and resistances decide how much of it actually lands
+func (g *Game) ApplyDamage(enemyID string, rawDamage float64, damageType pb.DamageType, attackerID string) error {
+	g.Lock()
+	defer g.Unlock()
+	return g.applyDamage(enemyID, DamageRoll{Amount: rawDamage, Type: damageType}, attackerID)
 }
 
-func (g *Game) ApplyDamage(enemyID string, incomingDamage float64, attackerID string) {
+// Attack rolls a click of the player with the damage calculator and hits the enemy with it
+func (g *Game) Attack(enemyID string, playerID string) error {
 	g.Lock()
 	defer g.Unlock()
-	// calculate enemy armor and resistance values here in future maybe?
-	// just substract damage for now
-	// also, TODO: find enemy by id
+
+	session, ok := g.Players[playerID]
+	if !ok {
+		return ErrPlayerNotFound
+	}
+	g.publish(EventClick, playerID, 1)
+	return g.applyDamage(enemyID, RollDamage(session.Data, g.rng), playerID)
+}
+
+func (g *Game) applyDamage(enemyID string, hit DamageRoll, attackerID string) error {
 	if len(g.Enemies) == 0 {
-		// TODO: spawn more enemies
 		log.Println("Attack ignored, no enemies to attack")
-		return
+		return ErrEnemyNotFound
 	}
 
-	enemy := g.Enemies[0]
+	enemyIndex, enemy := g.findActiveEnemy(enemyID)
+	if enemy == nil {
+		log.Printf("Attack ignored, enemy %s is not active", enemyID)
+		return ErrEnemyNotFound
+	}
+	incomingDamage := enemy.MitigateDamage(hit.Amount, hit.Type)
+	dealt := math.Min(incomingDamage, enemy.CurrentHealth)
+	enemy.recordDamage(attackerID, dealt)
+	if attackerID != "" {
+		g.publish(EventDamageDealt, attackerID, int64(math.Round(dealt)))
+	}
 	enemy.CurrentHealth -= incomingDamage
 
 	if enemy.CurrentHealth > 0 {
 		hitInfo := &pb.HitInfo{
 			DamageDealt: incomingDamage,
 			AttackerId:  attackerID,
+			Critical:    hit.Critical,
 		}
 
 		g.broadcastToAll(&pb.ServerToClient{
@@ -202,90 +487,88 @@ func (g *Game) ApplyDamage(enemyID string, incomingDamage float64, attackerID st
 					EnemyCurrentHp: enemy.CurrentHealth,
 					EnemyId:        enemy.ID,
 					LastHit:        hitInfo,
+					BossTimeLeft:   enemy.TimeLeft,
 				},
 			},
 		})
-		return
+		return nil
 	}
 
 	// destroy the enemy, spawn a new one, award xp, gold, hot wife
 	log.Printf("Enemy %s (Level %d) died", enemy.Name, enemy.Level)
 
-	baseGold := int64(BaseGoldPerKill * enemy.Level)
-	baseExp := int64(BaseExpPerKill * enemy.Level)
-
-	lastHitBonusGold := int64(float64(baseGold) * (LastHitGoldBonusMultiplier - 1))
-	lastHitBonusExp := int64(float64(baseExp) * (LastHitExpBonusMultiplier - 1))
-
-	for _, session := range g.Players {
-		player := session.Data
-		player.Resources.Gold += baseGold
-		player.Stats.Experience += baseExp
-
-		if player.GetId() == attackerID {
-			player.Resources.Gold += lastHitBonusGold
-			player.Stats.Experience += lastHitBonusExp
-			log.Printf("Player %s received a last hit bonus: +%d Gold, +%d Exp\n", player.GetName(), lastHitBonusGold, lastHitBonusExp)
-		}
-
-		g.checkForLevelUp(player)
+	g.awardKill(enemy, attackerID)
+	g.dropLoot(enemy, attackerID)
 
-		g.sendToPlayer(player.GetId(), &pb.ServerToClient{
-			Event: &pb.ServerToClient_PlayerStateUpdate{
-				PlayerStateUpdate: &pb.PlayerStateUpdate{
-					Player: player,
+	g.broadcastToAll(&pb.ServerToClient{
+		Event: &pb.ServerToClient_EnemyDefeated{
+			EnemyDefeated: &pb.EnemyDefeated{
+				EnemyId:  enemy.ID,
+				KillerId: attackerID,
+				LastHit: &pb.HitInfo{
+					DamageDealt: incomingDamage,
+					AttackerId:  attackerID,
+					Critical:    hit.Critical,
 				},
 			},
-		})
-	}
+		},
+	})
 
 	// fix the memory leak?
-	g.Enemies[0] = nil
+	copy(g.Enemies[enemyIndex:], g.Enemies[enemyIndex+1:])
+	g.Enemies[len(g.Enemies)-1] = nil
+	g.Enemies = g.Enemies[:len(g.Enemies)-1]
 
-	g.Enemies = g.Enemies[1:]
-	if len(g.Enemies) == 0 {
-		log.Println("All enemies have been defeated")
+	if g.Spawner != nil && g.Spawner.RecordKill(enemy) {
+		log.Printf("Stage %d cleared", enemy.Stage)
 		g.broadcastToAll(&pb.ServerToClient{
-			// TODO: add new field to proto for this case?
-			Event: &pb.ServerToClient_GameStateUpdate{
-				GameStateUpdate: &pb.GameStateUpdate{
-					EnemyId:        enemy.ID,
-					EnemyCurrentHp: 0.0,
+			Event: &pb.ServerToClient_StageCleared{
+				StageCleared: &pb.StageCleared{
+					Stage:     enemy.Stage,
+					NextStage: enemy.Stage + 1,
 				},
 			},
 		})
-		return
 	}
 
-	newEnemy := g.Enemies[0]
-	log.Printf("Enemy died. Spawning next enemy: %s with %.2f HP", newEnemy.Name, newEnemy.MaxHealth)
-
-	pbEnemy := &pb.Enemy{
-		Id:        newEnemy.ID,
-		Name:      newEnemy.Name,
-		MaxHp:     newEnemy.MaxHealth,
-		CurrentHp: newEnemy.MaxHealth,
-		Level:     newEnemy.Level,
-		Image:     newEnemy.Image,
+	g.fillEnemies()
+	if len(g.Enemies) == 0 {
+		log.Println("All enemies have been defeated")
+		return nil
 	}
+	if len(g.Enemies) < g.ActiveEnemies {
+		// nobody is waiting in line to take the free spot
+		return nil
+	}
+
+	newEnemy := g.Enemies[g.ActiveEnemies-1]
+	log.Printf("Enemy died. Spawning next enemy: %s with %.2f HP", newEnemy.Name, newEnemy.MaxHealth)
 
 	g.broadcastToAll(&pb.ServerToClient{
 		Event: &pb.ServerToClient_EnemySpawned{
 			EnemySpawned: &pb.NewEnemySpawned{
-				Enemy: pbEnemy,
+				Enemy: newEnemy.ToProto(),
 			},
 		},
 	})
+	return nil
 }
 
-func (g *Game) UpgradeWeapon(playerID string) {
+func rewardMultiplier(multiplier float64) float64 {
+	if multiplier == 0 {
+		return 1
+	}
+	return multiplier
+}
+
+func (g *Game) UpgradeWeapon(playerID string) error {
 	g.Lock()
 	defer g.Unlock()
 
 	session, ok := g.Players[playerID]
 	if !ok {
 		log.Printf("Attempted to upgrade weapon for a non-existent player3: %s\n", playerID)
-		return
+		return ErrPlayerNotFound
 	}
 
 	player := session.Data
@@ -295,34 +578,39 @@ func (g *Game) UpgradeWeapon(playerID string) {
 
 	if player.GetResources().GetGold() < upgradeCost {
 		log.Printf("Player %s has not enough gold to upgrade weapon. Needs %d, has %d\n", player.GetName(), upgradeCost, player.GetResources().GetGold())
-
-		// TODO: send INSUFFICIENT GOLD message to player
-		return
+		return fmt.Errorf("%w: upgrade costs %d", ErrNotEnoughGold, upgradeCost)
 	}
 
 	player.Resources.Gold -= upgradeCost
 	weapon.Level++
+	g.publish(EventWeaponUpgraded, playerID, 1)
 
 	log.Printf("Player %s upgraded '%s' to level %d for %d gold\n", player.GetName(), weapon.GetName(), weapon.GetLevel(), upgradeCost)
 
-	g.sendToPlayer(playerID, &pb.ServerToClient{
-		Event: &pb.ServerToClient_PlayerStateUpdate{
-			PlayerStateUpdate: &pb.PlayerStateUpdate{
-				Player: player,
-			},
-		},
-	})
+	g.sendPlayerState(player)
+	return nil
 }
 
 func (g *Game) CreateEnemyForLevel(level int64) *Enemy {
-	hp := CalculateEnemyHp(level)
-	stats := EnemyStats{
-		EnemyMaxHp: hp,
-		EnemyLevel: level,
+	g.Lock()
+	defer g.Unlock()
+
+	catalog := DefaultEnemyCatalog()
+	if g.Spawner != nil {
+		catalog = g.Spawner.Catalog
 	}
-	name := fmt.Sprintf("Level %d monster", level)
+	enemy := catalog.ForLevel(level, g.rng).NewEnemy(level, g.stageForLevel(level))
+	g.LastEnemyID = enemy.ID
+	g.Enemies = append(g.Enemies, enemy)
+	return enemy
+}
 
-	return g.CreateEnemy(stats, name, nil)
+// stageForLevel follows the stages of the spawner, without one the default stage size is used
+func (g *Game) stageForLevel(level int64) int64 {
+	if g.Spawner != nil {
+		return g.Spawner.StageForLevel(level)
+	}
+	return max(level-1, 0)/DefaultEnemiesPerStage + 1
 }
 
 func LoadAndProcessImage(filePath string, width uint, height uint) []byte {
@@ -351,24 +639,12 @@ func LoadAndProcessImage(filePath string, width uint, height uint) []byte {
 	return buffer.Bytes()
 }
 
-func (g *Game) CreateAndPrepareEnemy(level int64, imagePath string) *Enemy {
-	imageBytes := LoadAndProcessImage(imagePath, 384, 384)
-	hp := CalculateEnemyHp(level)
-	stats := EnemyStats{
-		EnemyMaxHp: hp,
-		EnemyLevel: level,
-	}
-
-	name := fmt.Sprintf("Level %d Goblin", level)
-
-	return &Enemy{
-		ID:            GenerateID(),
-		Name:          name,
-		MaxHealth:     stats.EnemyMaxHp,
-		CurrentHealth: stats.EnemyMaxHp,
-		Level:         stats.EnemyLevel,
-		Image:         imageBytes,
-	}
+// CreateAndPrepareEnemy builds an enemy of the archetype without adding it to the game,
+// the archetype image is already loaded by LoadEnemyCatalog
+func (g *Game) CreateAndPrepareEnemy(level int64, archetype *EnemyArchetype) *Enemy {
+	g.Lock()
+	defer g.Unlock()
+	return archetype.NewEnemy(level, g.stageForLevel(level))
 }
 
 func (g *Game) CreateEnemy(enemyStats EnemyStats, name string, image []byte) *Enemy {
@@ -377,12 +653,18 @@ func (g *Game) CreateEnemy(enemyStats EnemyStats, name string, image []byte) *En
 
 	g.LastEnemyID = GenerateID()
 	newEnemy := &Enemy{
-		ID:            g.LastEnemyID,
-		Name:          name,
-		MaxHealth:     enemyStats.EnemyMaxHp,
-		CurrentHealth: enemyStats.EnemyMaxHp,
-		Level:         enemyStats.EnemyLevel,
-		Image:         image,
+		ID:             g.LastEnemyID,
+		Name:           name,
+		MaxHealth:      enemyStats.EnemyMaxHp,
+		CurrentHealth:  enemyStats.EnemyMaxHp,
+		Level:          enemyStats.EnemyLevel,
+		Armor:          enemyStats.Armor,
+		HpRegen:        enemyStats.HpRegen,
+		Resistances:    enemyStats.Resistances,
+		GoldMultiplier: enemyStats.GoldMultiplier,
+		ExpMultiplier:  enemyStats.ExpMultiplier,
+		Loot:           enemyStats.Loot,
+		Image:          image,
 	}
 
 	g.Enemies = append(g.Enemies, newEnemy)
@@ -398,18 +680,38 @@ func CalculateEnemyHp(level int64) float64 {
 	return BaseHp * math.Pow(Multiplier, float64(level-1))
 }
 
-func (g *Game) checkForLevelUp(player *pb.Player) {
+// levelUp spends the exp of the player on as many levels as it allows, the surplus exp is kept
+func levelUp(player *pb.Player) int64 {
 	stats := player.GetStats()
-	if stats.Experience >= stats.GetNextLevelExp() {
+	var levelsGained int64
+	for stats.GetNextLevelExp() > 0 && stats.Experience >= stats.GetNextLevelExp() {
+		stats.Experience -= stats.GetNextLevelExp()
 		stats.Level++
-
-		// maybe subject to change in future
-		stats.Experience = 0
-
+		stats.StatPoints += StatPointsPerLevel
 		stats.NextLevelExp = int64(float64(stats.GetNextLevelExp()) * 1.5)
+		levelsGained++
+	}
+	return levelsGained
+}
 
-		log.Printf("Player %s has reached Level %d", player.GetName(), stats.GetLevel())
-
-		// maybe send some message to the player here?
+// checkForLevelUp levels the player up as many times as his exp allows and announces it
+func (g *Game) checkForLevelUp(player *pb.Player) {
+	stats := player.GetStats()
+	levelsGained := levelUp(player)
+	if levelsGained == 0 {
+		return
 	}
+
+	log.Printf("Player %s has reached Level %d", player.GetName(), stats.GetLevel())
+	g.publish(EventLevelReached, player.GetId(), stats.GetLevel())
+	g.broadcastToAll(&pb.ServerToClient{
+		Event: &pb.ServerToClient_LevelUp{
+			LevelUp: &pb.LevelUp{
+				PlayerId:     player.GetId(),
+				Level:        stats.GetLevel(),
+				LevelsGained: levelsGained,
+				StatPoints:   stats.GetStatPoints(),
+			},
+		},
+	})
 }
@@ -1,5 +1,54 @@
 package game
 
+import (
+	pb "clicker/gen/proto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyDamageTargetsEnemyByID(t *testing.T) {
+	game := NewGame()
+	game.ActiveEnemies = 2
+	first := game.CreateEnemy(EnemyStats{EnemyMaxHp: 100, EnemyLevel: 1}, "First", nil)
+	second := game.CreateEnemy(EnemyStats{EnemyMaxHp: 100, EnemyLevel: 1}, "Second", nil)
+	queued := game.CreateEnemy(EnemyStats{EnemyMaxHp: 100, EnemyLevel: 1}, "Queued", nil)
+
+	require.NoError(t, game.ApplyDamage(second.ID, 30, pb.DamageType_DAMAGE_TYPE_PHYSICAL, ""))
+	assert.Equal(t, 100.0, first.CurrentHealth)
+	assert.Equal(t, 70.0, second.CurrentHealth)
+
+	assert.ErrorIs(t, game.ApplyDamage(queued.ID, 30, pb.DamageType_DAMAGE_TYPE_PHYSICAL, ""), ErrEnemyNotFound, "enemies waiting in line can not be attacked")
+	assert.ErrorIs(t, game.ApplyDamage("missing", 30, pb.DamageType_DAMAGE_TYPE_PHYSICAL, ""), ErrEnemyNotFound)
+
+	require.NoError(t, game.ApplyDamage(first.ID, 100, pb.DamageType_DAMAGE_TYPE_PHYSICAL, ""))
+	active := game.GetActiveEnemies()
+	require.Len(t, active, 2)
+	assert.Equal(t, second.ID, active[0].ID)
+	assert.Equal(t, queued.ID, active[1].ID, "next enemy in line should become active")
+
+	require.NoError(t, game.ApplyDamage("", 5, pb.DamageType_DAMAGE_TYPE_PHYSICAL, ""))
+	assert.Equal(t, 65.0, second.CurrentHealth, "empty id should hit the first active enemy")
+}
+
+func TestUpgradeWeaponNeedsGold(t *testing.T) {
+	game := NewGame()
+	player := InitializePlayer("Tester")
+	game.AddPlayer(player, NewOutbox(nil))
+
+	player.Resources.Gold = 0
+	assert.ErrorIs(t, game.UpgradeWeapon(player.GetId()), ErrNotEnoughGold)
+	assert.Equal(t, int64(1), player.GetEquipment().GetWeapon().GetLevel())
+
+	player.Resources.Gold = WeaponUpgradeBaseCost
+	require.NoError(t, game.UpgradeWeapon(player.GetId()))
+	assert.Equal(t, int64(2), player.GetEquipment().GetWeapon().GetLevel())
+	assert.Zero(t, player.GetResources().GetGold())
+
+	assert.ErrorIs(t, game.UpgradeWeapon("nobody"), ErrPlayerNotFound)
+}
+
 //
 // import (
 // 	pb "clicker/gen/proto"
@@ -0,0 +1,579 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	MaxGuildNameLength  = 24 // in runes
+	MaxGuildMembers     = 30
+	DefaultGuildTaxRate = 0.05
+	MaxGuildTaxRate     = 0.5
+	// changed guilds are saved in batches by RunFlusher, so no action waits for the disk
+	GuildFlushInterval = 10 * time.Second
+)
+
+var (
+	ErrGuildNotFound    = errors.New("guild not found")
+	ErrAlreadyInGuild   = errors.New("player is already in a guild")
+	ErrNotInGuild       = errors.New("player is not in a guild")
+	ErrGuildFull        = errors.New("guild is full")
+	ErrGuildNameTaken   = errors.New("guild name is taken")
+	ErrInvalidGuildName = errors.New("invalid guild name")
+	ErrNotEnoughRights  = errors.New("guild role does not allow this")
+	ErrInvalidTaxRate   = errors.New("invalid guild tax rate")
+	ErrInvalidGuildRole = errors.New("invalid guild role")
+	ErrUnknownGuildBuff = errors.New("unknown guild buff")
+)
+
+// GuildBuffOffer is a damage buff for the whole guild paid from the guild bank
+type GuildBuffOffer struct {
+	ID       string
+	Name     string
+	Price    int64
+	Value    float64
+	Duration time.Duration
+}
+
+var GuildBuffOffers = []*GuildBuffOffer{
+	{ID: "war_banner", Name: "Боевое знамя", Price: 2000, Value: 0.25, Duration: 10 * time.Minute},
+	{ID: "battle_hymn", Name: "Боевой гимн", Price: 5000, Value: 0.5, Duration: 10 * time.Minute},
+	{ID: "dragon_blessing", Name: "Благословение дракона", Price: 20000, Value: 1, Duration: 30 * time.Minute},
+}
+
+func (o *GuildBuffOffer) ToProto() *pb.GuildBuffOffer {
+	return &pb.GuildBuffOffer{
+		Id:              o.ID,
+		Name:            o.Name,
+		Price:           o.Price,
+		Value:           o.Value,
+		DurationSeconds: int64(o.Duration.Seconds()),
+	}
+}
+
+func findGuildBuffOffer(id string) *GuildBuffOffer {
+	for _, offer := range GuildBuffOffers {
+		if offer.ID == id {
+			return offer
+		}
+	}
+	return nil
+}
+
+// GuildRegistry holds every guild, it is shared by all rooms.
+// Games call it under their own lock, so it must never call back into a game
+type GuildRegistry struct {
+	mu      sync.Mutex
+	store   GuildStore
+	guilds  map[string]*pb.Guild
+	members map[string]string // player id -> guild id
+	dirty   map[string]bool   // guilds with unsaved changes
+	deleted map[string]bool   // disbanded guilds still in the store
+
+	// keeps the flushes in order, so an older copy never overwrites a newer one
+	flushMu sync.Mutex
+}
+
+// NewGuildRegistry loads every saved guild from the store
+func NewGuildRegistry(store GuildStore) (*GuildRegistry, error) {
+	saved, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &GuildRegistry{
+		store:   store,
+		guilds:  make(map[string]*pb.Guild, len(saved)),
+		members: make(map[string]string),
+		dirty:   make(map[string]bool),
+		deleted: make(map[string]bool),
+	}
+	for _, guild := range saved {
+		r.guilds[guild.GetId()] = guild
+		for _, member := range guild.GetMembers() {
+			r.members[member.GetPlayerId()] = guild.GetId()
+		}
+	}
+	return r, nil
+}
+
+// markDirty queues the guild for the next Flush
+func (r *GuildRegistry) markDirty(guild *pb.Guild) {
+	r.dirty[guild.GetId()] = true
+}
+
+func (r *GuildRegistry) guildOf(playerID string) (*pb.Guild, *pb.GuildMember, error) {
+	guild, ok := r.guilds[r.members[playerID]]
+	if !ok {
+		return nil, nil, ErrNotInGuild
+	}
+	for _, member := range guild.GetMembers() {
+		if member.GetPlayerId() == playerID {
+			return guild, member, nil
+		}
+	}
+	return nil, nil, ErrNotInGuild
+}
+
+// GuildOf returns the id of the player guild, empty if there is none
+func (r *GuildRegistry) GuildOf(playerID string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.members[playerID]
+}
+
+// Guild returns a copy of the guild
+func (r *GuildRegistry) Guild(guildID string) (*pb.Guild, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	guild, ok := r.guilds[guildID]
+	if !ok {
+		return nil, ErrGuildNotFound
+	}
+	return proto.Clone(guild).(*pb.Guild), nil
+}
+
+// List returns copies of every guild sorted by name
+func (r *GuildRegistry) List() []*pb.Guild {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	guilds := make([]*pb.Guild, 0, len(r.guilds))
+	for _, guild := range r.guilds {
+		guilds = append(guilds, proto.Clone(guild).(*pb.Guild))
+	}
+	sort.Slice(guilds, func(i, j int) bool { return guilds[i].GetName() < guilds[j].GetName() })
+	return guilds
+}
+
+func (r *GuildRegistry) Create(player *pb.Player, name string, now time.Time) (*pb.Guild, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.members[player.GetId()]; ok {
+		return nil, ErrAlreadyInGuild
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxGuildNameLength {
+		return nil, fmt.Errorf("%w: the name must have 1 to %d characters", ErrInvalidGuildName, MaxGuildNameLength)
+	}
+	for _, guild := range r.guilds {
+		if strings.EqualFold(guild.GetName(), name) {
+			return nil, ErrGuildNameTaken
+		}
+	}
+
+	guild := &pb.Guild{
+		Id:        GenerateID(),
+		Name:      name,
+		TaxRate:   DefaultGuildTaxRate,
+		CreatedAt: now.Unix(),
+		Members: []*pb.GuildMember{{
+			PlayerId:   player.GetId(),
+			PlayerName: player.GetName(),
+			Role:       pb.GuildRole_GUILD_ROLE_LEADER,
+			JoinedAt:   now.Unix(),
+		}},
+	}
+	r.guilds[guild.Id] = guild
+	r.members[player.GetId()] = guild.Id
+	r.markDirty(guild)
+	log.Printf("Player %s founded guild '%s' (ID: %s)", player.GetName(), guild.Name, guild.Id)
+	return proto.Clone(guild).(*pb.Guild), nil
+}
+
+func (r *GuildRegistry) Join(player *pb.Player, guildID string, now time.Time) (*pb.Guild, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.members[player.GetId()]; ok {
+		return nil, ErrAlreadyInGuild
+	}
+	guild, ok := r.guilds[guildID]
+	if !ok {
+		return nil, ErrGuildNotFound
+	}
+	if len(guild.Members) >= MaxGuildMembers {
+		return nil, ErrGuildFull
+	}
+
+	guild.Members = append(guild.Members, &pb.GuildMember{
+		PlayerId:   player.GetId(),
+		PlayerName: player.GetName(),
+		Role:       pb.GuildRole_GUILD_ROLE_MEMBER,
+		JoinedAt:   now.Unix(),
+	})
+	r.members[player.GetId()] = guild.Id
+	r.markDirty(guild)
+	return proto.Clone(guild).(*pb.Guild), nil
+}
+
+// Leave takes the player out of his guild. A leaving leader hands the guild over to
+// the senior officer (or the oldest member), the last member leaving disbands it
+func (r *GuildRegistry) Leave(playerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	guild, member, err := r.guildOf(playerID)
+	if err != nil {
+		return err
+	}
+	delete(r.members, playerID)
+	guild.Members = slices.DeleteFunc(guild.Members, func(m *pb.GuildMember) bool { return m == member })
+
+	if len(guild.Members) == 0 {
+		delete(r.guilds, guild.Id)
+		delete(r.dirty, guild.Id)
+		r.deleted[guild.Id] = true
+		log.Printf("Guild '%s' (ID: %s) was disbanded", guild.Name, guild.Id)
+		return nil
+	}
+
+	if member.Role == pb.GuildRole_GUILD_ROLE_LEADER {
+		// members are kept in the order they joined
+		successor := guild.Members[0]
+		for _, m := range guild.Members {
+			if m.Role > successor.Role {
+				successor = m
+			}
+		}
+		successor.Role = pb.GuildRole_GUILD_ROLE_LEADER
+	}
+	r.markDirty(guild)
+	return nil
+}
+
+// SetRole lets the leader promote or demote a member, making somebody the leader
+// hands the leadership over and leaves the old leader an officer
+func (r *GuildRegistry) SetRole(actorID string, targetID string, role pb.GuildRole) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	guild, actor, err := r.guildOf(actorID)
+	if err != nil {
+		return err
+	}
+	if actor.Role != pb.GuildRole_GUILD_ROLE_LEADER {
+		return ErrNotEnoughRights
+	}
+	if _, ok := pb.GuildRole_name[int32(role)]; !ok || role == pb.GuildRole_GUILD_ROLE_UNSPECIFIED || actorID == targetID {
+		return ErrInvalidGuildRole
+	}
+	var target *pb.GuildMember
+	for _, m := range guild.Members {
+		if m.PlayerId == targetID {
+			target = m
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("%w: %s is not a member", ErrPlayerNotFound, targetID)
+	}
+
+	if role == pb.GuildRole_GUILD_ROLE_LEADER {
+		actor.Role = pb.GuildRole_GUILD_ROLE_OFFICER
+	}
+	target.Role = role
+	r.markDirty(guild)
+	return nil
+}
+
+// SetTax changes the share of the kill gold every member pays to the bank, leader only
+func (r *GuildRegistry) SetTax(actorID string, rate float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	guild, actor, err := r.guildOf(actorID)
+	if err != nil {
+		return err
+	}
+	if actor.Role != pb.GuildRole_GUILD_ROLE_LEADER {
+		return ErrNotEnoughRights
+	}
+	if rate < 0 || rate > MaxGuildTaxRate {
+		return fmt.Errorf("%w: must be between 0 and %.2f", ErrInvalidTaxRate, MaxGuildTaxRate)
+	}
+	guild.TaxRate = rate
+	r.markDirty(guild)
+	return nil
+}
+
+// BuyBuff pays for a guild buff from the bank, officers and the leader only.
+// Buying an active buff again extends it
+func (r *GuildRegistry) BuyBuff(actorID string, buffID string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	guild, actor, err := r.guildOf(actorID)
+	if err != nil {
+		return err
+	}
+	if actor.Role < pb.GuildRole_GUILD_ROLE_OFFICER {
+		return ErrNotEnoughRights
+	}
+	offer := findGuildBuffOffer(buffID)
+	if offer == nil {
+		return ErrUnknownGuildBuff
+	}
+	if guild.Bank < offer.Price {
+		return fmt.Errorf("%w: %s costs %d, the guild bank has %d", ErrNotEnoughGold, offer.Name, offer.Price, guild.Bank)
+	}
+
+	guild.Bank -= offer.Price
+	pruneGuildBuffs(guild, now)
+	for _, buff := range guild.Buffs {
+		if buff.BuffId == offer.ID {
+			buff.ExpiresAt += int64(offer.Duration.Seconds())
+			r.markDirty(guild)
+			return nil
+		}
+	}
+	guild.Buffs = append(guild.Buffs, &pb.GuildBuff{
+		BuffId:    offer.ID,
+		Name:      offer.Name,
+		Value:     offer.Value,
+		ExpiresAt: now.Add(offer.Duration).Unix(),
+	})
+	r.markDirty(guild)
+	log.Printf("Guild '%s' bought %s for %d gold", guild.Name, offer.Name, offer.Price)
+	return nil
+}
+
+func pruneGuildBuffs(guild *pb.Guild, now time.Time) {
+	guild.Buffs = slices.DeleteFunc(guild.Buffs, func(b *pb.GuildBuff) bool { return b.ExpiresAt <= now.Unix() })
+}
+
+// TaxRate is the share of kill gold the player pays to his guild, 0 without a guild
+func (r *GuildRegistry) TaxRate(playerID string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	guild, ok := r.guilds[r.members[playerID]]
+	if !ok {
+		return 0
+	}
+	return guild.TaxRate
+}
+
+// Deposit puts the gold into the bank of the player guild, it is saved on the next Flush
+func (r *GuildRegistry) Deposit(playerID string, gold int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	guild, ok := r.guilds[r.members[playerID]]
+	if !ok || gold <= 0 {
+		return
+	}
+	guild.Bank += gold
+	r.markDirty(guild)
+}
+
+// DamageBonus is the sum of the active guild buffs of the player
+func (r *GuildRegistry) DamageBonus(playerID string, now time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	guild, ok := r.guilds[r.members[playerID]]
+	if !ok {
+		return 0
+	}
+	bonus := 0.0
+	for _, buff := range guild.Buffs {
+		if buff.ExpiresAt > now.Unix() {
+			bonus += buff.Value
+		}
+	}
+	return bonus
+}
+
+// Flush saves the guilds changed since the last flush and deletes the disbanded ones.
+// The registry is only locked to copy them, games never wait for the disk
+func (r *GuildRegistry) Flush() {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+
+	r.mu.Lock()
+	changed := make([]*pb.Guild, 0, len(r.dirty))
+	for id := range r.dirty {
+		if guild, ok := r.guilds[id]; ok {
+			changed = append(changed, proto.Clone(guild).(*pb.Guild))
+		}
+	}
+	deleted := make([]string, 0, len(r.deleted))
+	for id := range r.deleted {
+		deleted = append(deleted, id)
+	}
+	clear(r.dirty)
+	clear(r.deleted)
+	r.mu.Unlock()
+
+	for _, guild := range changed {
+		if err := r.store.Save(guild); err != nil {
+			log.Printf("Could not save guild %s: %v", guild.GetId(), err)
+		}
+	}
+	for _, id := range deleted {
+		if err := r.store.Delete(id); err != nil {
+			log.Printf("Could not delete guild %s: %v", id, err)
+		}
+	}
+}
+
+// RunFlusher saves the changed guilds every interval until ctx is done
+func (r *GuildRegistry) RunFlusher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			r.Flush()
+			return
+		case <-ticker.C:
+			r.Flush()
+		}
+	}
+}
+
+func (g *Game) guildSession(playerID string) (*PlayerSession, error) {
+	session, ok := g.Players[playerID]
+	if !ok {
+		return nil, ErrPlayerNotFound
+	}
+	if g.Guilds == nil {
+		return nil, ErrGuildNotFound
+	}
+	return session, nil
+}
+
+func (g *Game) CreateGuild(playerID string, name string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	session, err := g.guildSession(playerID)
+	if err != nil {
+		return err
+	}
+	guild, err := g.Guilds.Create(session.Data, name, g.now())
+	if err != nil {
+		return err
+	}
+	session.Data.GuildId = guild.GetId()
+	g.syncGuildBonus(session.Data)
+	g.sendPlayerState(session.Data)
+	return nil
+}
+
+func (g *Game) JoinGuild(playerID string, guildID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	session, err := g.guildSession(playerID)
+	if err != nil {
+		return err
+	}
+	guild, err := g.Guilds.Join(session.Data, guildID, g.now())
+	if err != nil {
+		return err
+	}
+	session.Data.GuildId = guild.GetId()
+	g.syncGuildBonus(session.Data)
+	g.sendPlayerState(session.Data)
+	return nil
+}
+
+func (g *Game) LeaveGuild(playerID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	session, err := g.guildSession(playerID)
+	if err != nil {
+		return err
+	}
+	if err := g.Guilds.Leave(playerID); err != nil {
+		return err
+	}
+	session.Data.GuildId = ""
+	g.syncGuildBonus(session.Data)
+	g.sendPlayerState(session.Data)
+	return nil
+}
+
+func (g *Game) SetGuildRole(playerID string, targetID string, role pb.GuildRole) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if _, err := g.guildSession(playerID); err != nil {
+		return err
+	}
+	return g.Guilds.SetRole(playerID, targetID, role)
+}
+
+func (g *Game) SetGuildTax(playerID string, rate float64) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if _, err := g.guildSession(playerID); err != nil {
+		return err
+	}
+	return g.Guilds.SetTax(playerID, rate)
+}
+
+func (g *Game) BuyGuildBuff(playerID string, buffID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if _, err := g.guildSession(playerID); err != nil {
+		return err
+	}
+	if err := g.Guilds.BuyBuff(playerID, buffID, g.now()); err != nil {
+		return err
+	}
+	// members in other rooms get it on the next tick of their room
+	g.refreshGuildBonuses()
+	return nil
+}
+
+// payGuildTax moves the guild share of the kill gold to the bank and returns it
+func (g *Game) payGuildTax(playerID string, gold int64) int64 {
+	if g.Guilds == nil {
+		return 0
+	}
+	tax := int64(float64(gold) * g.Guilds.TaxRate(playerID))
+	g.Guilds.Deposit(playerID, tax)
+	return tax
+}
+
+// syncGuildBonus copies the active guild buffs into the player, PlayerDamage reads them
+// from there so the client shows the damage the server deals. Reports if the bonus changed
+func (g *Game) syncGuildBonus(player *pb.Player) bool {
+	bonus := 0.0
+	if g.Guilds != nil {
+		bonus = g.Guilds.DamageBonus(player.GetId(), g.now())
+	}
+	if bonus == player.GetGuildDamageBonus() {
+		return false
+	}
+	player.GuildDamageBonus = bonus
+	return true
+}
+
+// refreshGuildBonuses picks up buffs bought or expired since the last call, in this room or any other
+func (g *Game) refreshGuildBonuses() {
+	for _, session := range g.Players {
+		if g.syncGuildBonus(session.Data) {
+			g.sendPlayerState(session.Data)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGuildGame(t *testing.T, names ...string) (*Game, map[string]*pb.Player) {
+	guilds, err := NewGuildRegistry(NewMemoryGuildStore())
+	require.NoError(t, err)
+	game := NewGame()
+	game.Guilds = guilds
+	game.Achievements = nil
+	players := make(map[string]*pb.Player)
+	for _, name := range names {
+		player := InitializePlayer(name)
+		player.Id = name
+		player.Resources.Gold = 0
+		game.AddPlayer(player, NewOutbox(nil))
+		players[name] = player
+	}
+	return game, players
+}
+
+func guildRole(t *testing.T, guilds *GuildRegistry, playerID string) pb.GuildRole {
+	guild, err := guilds.Guild(guilds.GuildOf(playerID))
+	require.NoError(t, err)
+	for _, member := range guild.GetMembers() {
+		if member.GetPlayerId() == playerID {
+			return member.GetRole()
+		}
+	}
+	return pb.GuildRole_GUILD_ROLE_UNSPECIFIED
+}
+
+func TestGuildMembership(t *testing.T) {
+	game, players := newGuildGame(t, "alice", "bob", "carol")
+
+	require.NoError(t, game.CreateGuild("alice", "  Драконы "))
+	guildID := players["alice"].GetGuildId()
+	require.NotEmpty(t, guildID)
+	assert.ErrorIs(t, game.CreateGuild("bob", "драконы"), ErrGuildNameTaken)
+	assert.ErrorIs(t, game.CreateGuild("bob", ""), ErrInvalidGuildName)
+	assert.ErrorIs(t, game.CreateGuild("alice", "Другая"), ErrAlreadyInGuild)
+	assert.ErrorIs(t, game.JoinGuild("bob", "nope"), ErrGuildNotFound)
+
+	require.NoError(t, game.JoinGuild("bob", guildID))
+	require.NoError(t, game.JoinGuild("carol", guildID))
+	assert.Equal(t, guildID, players["bob"].GetGuildId())
+	assert.Equal(t, pb.GuildRole_GUILD_ROLE_MEMBER, guildRole(t, game.Guilds, "bob"))
+
+	assert.ErrorIs(t, game.SetGuildRole("bob", "carol", pb.GuildRole_GUILD_ROLE_OFFICER), ErrNotEnoughRights)
+	require.NoError(t, game.SetGuildRole("alice", "carol", pb.GuildRole_GUILD_ROLE_OFFICER))
+
+	// the officer takes over, not the older member
+	require.NoError(t, game.LeaveGuild("alice"))
+	assert.Empty(t, players["alice"].GetGuildId())
+	assert.Equal(t, pb.GuildRole_GUILD_ROLE_LEADER, guildRole(t, game.Guilds, "carol"))
+
+	require.NoError(t, game.SetGuildRole("carol", "bob", pb.GuildRole_GUILD_ROLE_LEADER))
+	assert.Equal(t, pb.GuildRole_GUILD_ROLE_LEADER, guildRole(t, game.Guilds, "bob"))
+	assert.Equal(t, pb.GuildRole_GUILD_ROLE_OFFICER, guildRole(t, game.Guilds, "carol"), "the old leader steps down")
+
+	require.NoError(t, game.LeaveGuild("bob"))
+	require.NoError(t, game.LeaveGuild("carol"))
+	_, err := game.Guilds.Guild(guildID)
+	assert.ErrorIs(t, err, ErrGuildNotFound, "the last member disbands the guild")
+	assert.ErrorIs(t, game.LeaveGuild("carol"), ErrNotInGuild)
+}
+
+func TestGuildTaxAndBuffs(t *testing.T) {
+	game, players := newGuildGame(t, "alice", "bob")
+	now := time.Unix(1_000_000, 0)
+	game.Clock = func() time.Time { return now }
+
+	require.NoError(t, game.CreateGuild("alice", "Драконы"))
+	guildID := players["alice"].GetGuildId()
+	require.NoError(t, game.JoinGuild("bob", guildID))
+	assert.ErrorIs(t, game.SetGuildTax("bob", 0.2), ErrNotEnoughRights)
+	assert.ErrorIs(t, game.SetGuildTax("alice", MaxGuildTaxRate+0.1), ErrInvalidTaxRate)
+	require.NoError(t, game.SetGuildTax("alice", 0.2))
+
+	// a lone kill is worth 10 gold plus the last hit bonus
+	game.CreateEnemy(EnemyStats{EnemyMaxHp: 1, EnemyLevel: 1}, "Rat", nil)
+	require.NoError(t, game.ApplyDamage("", 10, pb.DamageType_DAMAGE_TYPE_PHYSICAL, "bob"))
+	assert.Equal(t, int64(12), players["bob"].GetResources().GetGold())
+	guild, err := game.Guilds.Guild(guildID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), guild.GetBank())
+
+	damage := PlayerDamage(players["bob"])
+	assert.ErrorIs(t, game.BuyGuildBuff("alice", "war_banner"), ErrNotEnoughGold)
+	game.Guilds.Deposit("alice", 4000)
+	assert.ErrorIs(t, game.BuyGuildBuff("bob", "war_banner"), ErrNotEnoughRights)
+	assert.ErrorIs(t, game.BuyGuildBuff("alice", "nope"), ErrUnknownGuildBuff)
+	require.NoError(t, game.BuyGuildBuff("alice", "war_banner"))
+	require.NoError(t, game.BuyGuildBuff("alice", "war_banner"))
+	guild, err = game.Guilds.Guild(guildID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), guild.GetBank())
+	require.Len(t, guild.GetBuffs(), 1, "buying again extends the buff")
+	assert.Equal(t, now.Add(20*time.Minute).Unix(), guild.GetBuffs()[0].GetExpiresAt())
+
+	// the bonus is part of the player, so the damage calculator sees it on both sides
+	assert.Equal(t, 0.25, players["bob"].GetGuildDamageBonus())
+	assert.InDelta(t, damage*1.25, PlayerDamage(players["bob"]), 1e-9, "every member hits harder")
+	require.NoError(t, game.LeaveGuild("bob"))
+	assert.InDelta(t, damage, PlayerDamage(players["bob"]), 1e-9)
+	require.NoError(t, game.JoinGuild("bob", guildID))
+	assert.Equal(t, 0.25, players["bob"].GetGuildDamageBonus(), "joining picks up the active buffs")
+
+	now = now.Add(21 * time.Minute)
+	game.Tick(time.Second)
+	assert.Zero(t, players["bob"].GetGuildDamageBonus())
+	assert.InDelta(t, damage, PlayerDamage(players["bob"]), 1e-9, "the buff has expired")
+}
+
+func TestGuildsArePersisted(t *testing.T) {
+	store := NewMemoryGuildStore()
+	guilds, err := NewGuildRegistry(store)
+	require.NoError(t, err)
+	leader := InitializePlayer("alice")
+	guild, err := guilds.Create(leader, "Драконы", time.Now())
+	require.NoError(t, err)
+	guilds.Deposit(leader.GetId(), 50)
+
+	reloaded, err := NewGuildRegistry(store)
+	require.NoError(t, err)
+	assert.Empty(t, reloaded.GuildOf(leader.GetId()), "changes wait for the flush")
+
+	guilds.Flush()
+	reloaded, err = NewGuildRegistry(store)
+	require.NoError(t, err)
+	assert.Equal(t, guild.GetId(), reloaded.GuildOf(leader.GetId()))
+	saved, err := reloaded.Guild(guild.GetId())
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), saved.GetBank())
+
+	require.NoError(t, guilds.Leave(leader.GetId()))
+	guilds.Flush()
+	stored, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, stored, "the disbanded guild is deleted on the flush")
+}
@@ -0,0 +1,196 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"errors"
+	"log"
+)
+
+const MaxInventorySize = 30
+
+var (
+	ErrItemNotFound   = errors.New("item not found in inventory")
+	ErrInventoryFull  = errors.New("inventory is full")
+	ErrInvalidItem    = errors.New("item is not valid for its slot")
+	ErrSlotEmpty      = errors.New("nothing is equipped in this slot")
+	ErrWeaponRequired = errors.New("weapon can not be unequipped, equip another one instead")
+)
+
+func validateItem(item *pb.Item) error {
+	switch item.GetSlot() {
+	case pb.ItemSlot_ITEM_SLOT_WEAPON:
+		if item.GetWeapon() == nil {
+			return ErrInvalidItem
+		}
+	case pb.ItemSlot_ITEM_SLOT_ARMOR, pb.ItemSlot_ITEM_SLOT_TRINKET:
+		if item.GetWeapon() != nil {
+			return ErrInvalidItem
+		}
+	default:
+		return ErrInvalidItem
+	}
+	return nil
+}
+
+// GiveItem puts the item into the player inventory
+func (g *Game) GiveItem(playerID string, item *pb.Item) error {
+	g.Lock()
+	defer g.Unlock()
+
+	session, ok := g.Players[playerID]
+	if !ok {
+		return ErrPlayerNotFound
+	}
+	if err := giveItem(session.Data, item); err != nil {
+		return err
+	}
+	g.sendPlayerState(session.Data)
+	return nil
+}
+
+func giveItem(player *pb.Player, item *pb.Item) error {
+	if err := validateItem(item); err != nil {
+		return err
+	}
+	if len(player.GetInventory()) >= MaxInventorySize {
+		return ErrInventoryFull
+	}
+	if item.GetId() == "" {
+		item.Id = GenerateID()
+	}
+	player.Inventory = append(player.Inventory, item)
+	return nil
+}
+
+// takeItem removes the item from the inventory and returns it
+func takeItem(player *pb.Player, itemID string) (*pb.Item, error) {
+	for i, item := range player.GetInventory() {
+		if item.GetId() == itemID {
+			player.Inventory = append(player.Inventory[:i], player.Inventory[i+1:]...)
+			return item, nil
+		}
+	}
+	return nil, ErrItemNotFound
+}
+
+// EquipItem moves the item from the inventory into its slot, whatever was there goes back to the inventory
+func (g *Game) EquipItem(playerID string, itemID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	session, ok := g.Players[playerID]
+	if !ok {
+		return ErrPlayerNotFound
+	}
+	player := session.Data
+	if player.Equipment == nil {
+		player.Equipment = &pb.PlayerEquipment{}
+	}
+
+	item, err := takeItem(player, itemID)
+	if err != nil {
+		return err
+	}
+	if err := validateItem(item); err != nil {
+		player.Inventory = append(player.Inventory, item)
+		return err
+	}
+
+	equipment := player.Equipment
+	switch item.GetSlot() {
+	case pb.ItemSlot_ITEM_SLOT_WEAPON:
+		// the inventory has a free spot now, the old weapon takes it
+		if old := equipment.GetWeapon(); old != nil {
+			player.Inventory = append(player.Inventory, WeaponItem(old))
+		}
+		equipment.Weapon = item.GetWeapon()
+	case pb.ItemSlot_ITEM_SLOT_ARMOR:
+		if old := equipment.GetArmor(); old != nil {
+			player.Inventory = append(player.Inventory, old)
+		}
+		equipment.Armor = item
+	case pb.ItemSlot_ITEM_SLOT_TRINKET:
+		if old := equipment.GetTrinket(); old != nil {
+			player.Inventory = append(player.Inventory, old)
+		}
+		equipment.Trinket = item
+	}
+
+	log.Printf("Player %s equipped '%s'", player.GetName(), item.GetName())
+	g.sendPlayerState(player)
+	return nil
+}
+
+// UnequipItem moves the item of the slot back to the inventory
+func (g *Game) UnequipItem(playerID string, slot pb.ItemSlot) error {
+	g.Lock()
+	defer g.Unlock()
+
+	session, ok := g.Players[playerID]
+	if !ok {
+		return ErrPlayerNotFound
+	}
+	player := session.Data
+	equipment := player.GetEquipment()
+
+	var item *pb.Item
+	switch slot {
+	case pb.ItemSlot_ITEM_SLOT_WEAPON:
+		return ErrWeaponRequired
+	case pb.ItemSlot_ITEM_SLOT_ARMOR:
+		item = equipment.GetArmor()
+	case pb.ItemSlot_ITEM_SLOT_TRINKET:
+		item = equipment.GetTrinket()
+	default:
+		return ErrInvalidItem
+	}
+	if item == nil {
+		return ErrSlotEmpty
+	}
+	if len(player.GetInventory()) >= MaxInventorySize {
+		return ErrInventoryFull
+	}
+
+	player.Inventory = append(player.Inventory, item)
+	if slot == pb.ItemSlot_ITEM_SLOT_ARMOR {
+		equipment.Armor = nil
+	} else {
+		equipment.Trinket = nil
+	}
+
+	log.Printf("Player %s unequipped '%s'", player.GetName(), item.GetName())
+	g.sendPlayerState(player)
+	return nil
+}
+
+// WeaponItem wraps a weapon so it can be kept in the inventory
+func WeaponItem(weapon *pb.Weapon) *pb.Item {
+	return &pb.Item{
+		Id:     GenerateID(),
+		ItemId: weapon.GetItemId(),
+		Name:   weapon.GetName(),
+		Slot:   pb.ItemSlot_ITEM_SLOT_WEAPON,
+		Weapon: weapon,
+	}
+}
+
+// equippedItems are the non weapon items contributing stats
+func equippedItems(player *pb.Player) []*pb.Item {
+	var items []*pb.Item
+	for _, item := range []*pb.Item{player.GetEquipment().GetArmor(), player.GetEquipment().GetTrinket()} {
+		if item != nil {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (g *Game) sendPlayerState(player *pb.Player) {
+	g.sendToPlayer(player.GetId(), &pb.ServerToClient{
+		Event: &pb.ServerToClient_PlayerStateUpdate{
+			PlayerStateUpdate: &pb.PlayerStateUpdate{
+				Player: player,
+			},
+		},
+	})
+}
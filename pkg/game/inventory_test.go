@@ -0,0 +1,51 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEquipAndUnequipItems(t *testing.T) {
+	game := NewGame()
+	player := InitializePlayer("collector")
+	game.AddPlayer(player, NewOutbox(nil))
+
+	sword := &pb.Item{ItemId: "iron_sword", Name: "Iron sword", Slot: pb.ItemSlot_ITEM_SLOT_WEAPON,
+		Weapon: &pb.Weapon{ItemId: "iron_sword", Name: "Iron sword", Level: 1, BaseDamage: 12, DamageGrowth: 3}}
+	ring := &pb.Item{ItemId: "ring", Name: "Ring", Slot: pb.ItemSlot_ITEM_SLOT_TRINKET, BonusDamage: 4, GoldFind: 0.25}
+
+	require.NoError(t, game.GiveItem(player.GetId(), sword))
+	require.NoError(t, game.GiveItem(player.GetId(), ring))
+	assert.ErrorIs(t, game.GiveItem(player.GetId(), &pb.Item{Slot: pb.ItemSlot_ITEM_SLOT_ARMOR, Weapon: &pb.Weapon{}}), ErrInvalidItem)
+
+	require.NoError(t, game.EquipItem(player.GetId(), sword.GetId()))
+	assert.Equal(t, "iron_sword", player.GetEquipment().GetWeapon().GetItemId())
+	require.Len(t, player.GetInventory(), 2, "old weapon goes to the inventory")
+	assert.Equal(t, "starter_stick", player.GetInventory()[1].GetWeapon().GetItemId())
+
+	require.NoError(t, game.EquipItem(player.GetId(), ring.GetId()))
+	assert.Equal(t, 16.0, PlayerDamage(player))
+	assert.Equal(t, 0.25, PlayerGoldFind(player))
+
+	assert.ErrorIs(t, game.EquipItem(player.GetId(), "missing"), ErrItemNotFound)
+	assert.ErrorIs(t, game.UnequipItem(player.GetId(), pb.ItemSlot_ITEM_SLOT_WEAPON), ErrWeaponRequired)
+	assert.ErrorIs(t, game.UnequipItem(player.GetId(), pb.ItemSlot_ITEM_SLOT_ARMOR), ErrSlotEmpty)
+
+	require.NoError(t, game.UnequipItem(player.GetId(), pb.ItemSlot_ITEM_SLOT_TRINKET))
+	assert.Nil(t, player.GetEquipment().GetTrinket())
+	assert.Equal(t, 12.0, PlayerDamage(player))
+}
+
+func TestInventoryLimit(t *testing.T) {
+	game := NewGame()
+	player := InitializePlayer("hoarder")
+	game.AddPlayer(player, NewOutbox(nil))
+
+	for i := 0; i < MaxInventorySize; i++ {
+		require.NoError(t, game.GiveItem(player.GetId(), &pb.Item{Name: "Pebble", Slot: pb.ItemSlot_ITEM_SLOT_TRINKET}))
+	}
+	assert.ErrorIs(t, game.GiveItem(player.GetId(), &pb.Item{Name: "Pebble", Slot: pb.ItemSlot_ITEM_SLOT_TRINKET}), ErrInventoryFull)
+}
@@ -0,0 +1,99 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"sort"
+)
+
+const (
+	DefaultLeaderboardSize = 10
+	MaxLeaderboardSize     = 100
+)
+
+var leaderboardEvents = map[pb.LeaderboardCategory]EventKind{
+	pb.LeaderboardCategory_LEADERBOARD_CATEGORY_TOTAL_DAMAGE:  EventDamageDealt,
+	pb.LeaderboardCategory_LEADERBOARD_CATEGORY_KILLS:         EventKill,
+	pb.LeaderboardCategory_LEADERBOARD_CATEGORY_HIGHEST_LEVEL: EventLevelReached,
+	pb.LeaderboardCategory_LEADERBOARD_CATEGORY_GOLD_EARNED:   EventGoldEarned,
+}
+
+func (g *Game) trackSessionStats(event GameEvent) {
+	session, ok := g.Players[event.PlayerID]
+	if !ok || event.Kind == EventLevelReached {
+		return
+	}
+	if session.sessionStats == nil {
+		session.sessionStats = make(map[EventKind]int64)
+	}
+	session.sessionStats[event.Kind] += event.Amount
+}
+
+// allTimeValue reads the lifetime counter of the category from the player profile
+func allTimeValue(player *pb.Player, category pb.LeaderboardCategory) int64 {
+	kind := leaderboardEvents[category]
+	value := player.GetCounters()[kind.String()]
+	if kind == EventLevelReached {
+		// the counter only moves on level ups and survives rebirths
+		value = max(value, player.GetStats().GetLevel())
+	}
+	return value
+}
+
+// Leaderboard ranks the players in the category. All time rankings include the offline
+// profiles (the server passes the players of other rooms there too), players of this room
+// replace their copies since they are fresher
+func (g *Game) Leaderboard(category pb.LeaderboardCategory, scope pb.LeaderboardScope, limit int, offline []*pb.Player) *pb.Leaderboard {
+	g.Lock()
+	defer g.Unlock()
+
+	if limit <= 0 {
+		limit = DefaultLeaderboardSize
+	}
+	limit = min(limit, MaxLeaderboardSize)
+
+	var entries []*pb.LeaderboardEntry
+	for _, session := range g.Players {
+		player := session.Data
+		value := allTimeValue(player, category)
+		if scope == pb.LeaderboardScope_LEADERBOARD_SCOPE_SESSION {
+			if kind := leaderboardEvents[category]; kind == EventLevelReached {
+				value = player.GetStats().GetLevel()
+			} else {
+				value = session.sessionStats[kind]
+			}
+		}
+		entries = append(entries, &pb.LeaderboardEntry{
+			PlayerId:   player.GetId(),
+			PlayerName: player.GetName(),
+			Value:      value,
+			Online:     true,
+		})
+	}
+	if scope == pb.LeaderboardScope_LEADERBOARD_SCOPE_ALL_TIME {
+		for _, player := range offline {
+			if _, ok := g.Players[player.GetId()]; ok {
+				continue
+			}
+			entries = append(entries, &pb.LeaderboardEntry{
+				PlayerId:   player.GetId(),
+				PlayerName: player.GetName(),
+				Value:      allTimeValue(player, category),
+			})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Value != entries[j].Value {
+			return entries[i].Value > entries[j].Value
+		}
+		return entries[i].PlayerName < entries[j].PlayerName
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	for i, entry := range entries {
+		entry.Rank = int32(i + 1)
+	}
+
+	return &pb.Leaderboard{Category: category, Scope: scope, Entries: entries}
+}
@@ -0,0 +1,48 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderboard(t *testing.T) {
+	game := NewGame()
+	game.Achievements = nil
+
+	online := InitializePlayer("online")
+	online.Counters = map[string]int64{"kills": 5}
+	game.AddPlayer(online, NewOutbox(nil))
+
+	veteran := InitializePlayer("veteran")
+	veteran.Counters = map[string]int64{"kills": 100, "level": 30}
+	stale := InitializePlayer("online")
+	stale.Id = online.Id
+	stale.Counters = map[string]int64{"kills": 1}
+	offline := []*pb.Player{veteran, stale}
+
+	game.CreateEnemy(EnemyStats{EnemyMaxHp: 1, EnemyLevel: 1}, "Rat", nil)
+	game.CreateEnemy(EnemyStats{EnemyMaxHp: 1e9, EnemyLevel: 1}, "Wall", nil)
+	require.NoError(t, game.ApplyDamage("", 10, pb.DamageType_DAMAGE_TYPE_PHYSICAL, online.GetId()))
+
+	kills := game.Leaderboard(pb.LeaderboardCategory_LEADERBOARD_CATEGORY_KILLS, pb.LeaderboardScope_LEADERBOARD_SCOPE_ALL_TIME, 0, offline)
+	require.Len(t, kills.GetEntries(), 2, "online players replace their stored profiles")
+	assert.Equal(t, "veteran", kills.GetEntries()[0].GetPlayerName())
+	assert.False(t, kills.GetEntries()[0].GetOnline())
+	assert.Equal(t, int32(2), kills.GetEntries()[1].GetRank())
+	assert.Equal(t, int64(6), kills.GetEntries()[1].GetValue())
+	assert.True(t, kills.GetEntries()[1].GetOnline())
+
+	session := game.Leaderboard(pb.LeaderboardCategory_LEADERBOARD_CATEGORY_KILLS, pb.LeaderboardScope_LEADERBOARD_SCOPE_SESSION, 0, offline)
+	require.Len(t, session.GetEntries(), 1, "offline players have no session")
+	assert.Equal(t, int64(1), session.GetEntries()[0].GetValue())
+
+	damage := game.Leaderboard(pb.LeaderboardCategory_LEADERBOARD_CATEGORY_TOTAL_DAMAGE, pb.LeaderboardScope_LEADERBOARD_SCOPE_SESSION, 0, nil)
+	assert.Equal(t, int64(1), damage.GetEntries()[0].GetValue(), "overkill damage does not count")
+
+	levels := game.Leaderboard(pb.LeaderboardCategory_LEADERBOARD_CATEGORY_HIGHEST_LEVEL, pb.LeaderboardScope_LEADERBOARD_SCOPE_ALL_TIME, 1, offline)
+	require.Len(t, levels.GetEntries(), 1)
+	assert.Equal(t, int64(30), levels.GetEntries()[0].GetValue())
+}
@@ -0,0 +1,299 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"strings"
+)
+
+// LootPolicy decides who of the players gets the item dropped by a killed enemy
+type LootPolicy int
+
+const (
+	LootToLastHitter LootPolicy = iota
+	LootToTopDamage
+	LootRoundRobin
+)
+
+// DefaultRarityWeights are used by loot tables that do not set their own
+var DefaultRarityWeights = map[pb.ItemRarity]int{
+	pb.ItemRarity_ITEM_RARITY_COMMON:    600,
+	pb.ItemRarity_ITEM_RARITY_UNCOMMON:  250,
+	pb.ItemRarity_ITEM_RARITY_RARE:      100,
+	pb.ItemRarity_ITEM_RARITY_EPIC:      40,
+	pb.ItemRarity_ITEM_RARITY_LEGENDARY: 10,
+}
+
+// RarityMultipliers scale every stat of an item of that rarity
+var RarityMultipliers = map[pb.ItemRarity]float64{
+	pb.ItemRarity_ITEM_RARITY_COMMON:    1.0,
+	pb.ItemRarity_ITEM_RARITY_UNCOMMON:  1.25,
+	pb.ItemRarity_ITEM_RARITY_RARE:      1.6,
+	pb.ItemRarity_ITEM_RARITY_EPIC:      2.2,
+	pb.ItemRarity_ITEM_RARITY_LEGENDARY: 3.0,
+}
+
+// ItemTemplate describes an item kind loot can be rolled from
+type ItemTemplate struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Slot        string  `json:"slot"` // weapon, armor or trinket
+	BonusDamage float64 `json:"bonus_damage"`
+	GoldFind    float64 `json:"gold_find"`
+	CritChance  float64 `json:"crit_chance"`
+
+	// weapons only
+	BaseDamage   float64 `json:"base_damage"`
+	DamageGrowth float64 `json:"damage_growth"`
+	DamageType   string  `json:"damage_type"`
+
+	slot       pb.ItemSlot
+	damageType pb.DamageType
+}
+
+type LootEntry struct {
+	ItemID string `json:"item"`
+	Weight int    `json:"weight"`
+
+	template *ItemTemplate
+}
+
+// LootTable is what an enemy archetype can drop
+type LootTable struct {
+	DropChance float64     `json:"drop_chance"`
+	Entries    []LootEntry `json:"entries"`
+	// rarity name ("rare") -> weight, DefaultRarityWeights if empty
+	RarityWeights map[string]int `json:"rarity_weights"`
+
+	rarityWeights map[pb.ItemRarity]int
+}
+
+// NewItem creates a copy of the template with stats scaled by rarity
+func (t *ItemTemplate) NewItem(rarity pb.ItemRarity) *pb.Item {
+	multiplier := RarityMultipliers[rarity]
+	item := &pb.Item{
+		Id:          GenerateID(),
+		ItemId:      t.ID,
+		Name:        t.Name,
+		Slot:        t.slot,
+		Rarity:      rarity,
+		BonusDamage: float32(t.BonusDamage * multiplier),
+		GoldFind:    float32(t.GoldFind * multiplier),
+		CritChance:  float32(t.CritChance * multiplier),
+	}
+	if t.slot == pb.ItemSlot_ITEM_SLOT_WEAPON {
+		item.Weapon = &pb.Weapon{
+			ItemId:       t.ID,
+			Name:         t.Name,
+			Level:        1,
+			BaseDamage:   float32(t.BaseDamage * multiplier),
+			DamageGrowth: float32(t.DamageGrowth * multiplier),
+			DamageType:   t.damageType,
+		}
+	}
+	return item
+}
+
+// RollLoot decides if the table drops anything and what, nil means no drop
+func RollLoot(table *LootTable, rng *rand.Rand) *pb.Item {
+	if table == nil || len(table.Entries) == 0 || rng.Float64() >= table.DropChance {
+		return nil
+	}
+
+	// entries of a table built in code have no template, they are skipped like the empty ones
+	usable := func(entry LootEntry) bool { return entry.template != nil && entry.Weight > 0 }
+	totalWeight := 0
+	for _, entry := range table.Entries {
+		if usable(entry) {
+			totalWeight += entry.Weight
+		}
+	}
+	if totalWeight == 0 {
+		return nil
+	}
+	roll := rng.IntN(totalWeight)
+	var template *ItemTemplate
+	for _, entry := range table.Entries {
+		if !usable(entry) {
+			continue
+		}
+		if roll < entry.Weight {
+			template = entry.template
+			break
+		}
+		roll -= entry.Weight
+	}
+
+	return template.NewItem(rollRarity(table.rarityWeights, rng))
+}
+
+func rollRarity(weights map[pb.ItemRarity]int, rng *rand.Rand) pb.ItemRarity {
+	if len(weights) == 0 {
+		weights = DefaultRarityWeights
+	}
+
+	// walk the tiers in order so the same seed always gives the same rarity
+	totalWeight := 0
+	for rarity := pb.ItemRarity_ITEM_RARITY_COMMON; rarity <= pb.ItemRarity_ITEM_RARITY_LEGENDARY; rarity++ {
+		totalWeight += weights[rarity]
+	}
+	roll := rng.IntN(totalWeight)
+	for rarity := pb.ItemRarity_ITEM_RARITY_COMMON; rarity <= pb.ItemRarity_ITEM_RARITY_LEGENDARY; rarity++ {
+		if roll < weights[rarity] {
+			return rarity
+		}
+		roll -= weights[rarity]
+	}
+	return pb.ItemRarity_ITEM_RARITY_COMMON
+}
+
+// lootRecipient picks who gets the drop of the enemy according to the loot policy
+func (g *Game) lootRecipient(enemy *Enemy, lastHitterID string) string {
+	switch g.LootPolicy {
+	case LootToTopDamage:
+		var topID string
+		var topDamage float64
+		for _, id := range enemy.participants() {
+			if damage := enemy.damageDealt[id]; damage > topDamage {
+				topID, topDamage = id, damage
+			}
+		}
+		return topID
+	case LootRoundRobin:
+		participants := enemy.participants()
+		if len(participants) == 0 {
+			return ""
+		}
+		recipient := participants[g.lootTurn%len(participants)]
+		g.lootTurn++
+		return recipient
+	default:
+		return lastHitterID
+	}
+}
+
+// dropLoot rolls the loot of a killed enemy and hands it to the chosen player
+func (g *Game) dropLoot(enemy *Enemy, lastHitterID string) {
+	item := RollLoot(enemy.Loot, g.rng)
+	if item == nil {
+		return
+	}
+
+	recipientID := g.lootRecipient(enemy, lastHitterID)
+	session, ok := g.Players[recipientID]
+	if !ok {
+		log.Printf("Loot '%s' from %s is lost, nobody to receive it", item.GetName(), enemy.Name)
+		return
+	}
+	if err := giveItem(session.Data, item); err != nil {
+		log.Printf("Loot '%s' for player %s is lost: %v", item.GetName(), session.Data.GetName(), err)
+		return
+	}
+
+	log.Printf("Player %s looted %s '%s' from %s", session.Data.GetName(), item.GetRarity(), item.GetName(), enemy.Name)
+	g.broadcastToAll(&pb.ServerToClient{
+		Event: &pb.ServerToClient_LootDropped{
+			LootDropped: &pb.LootDropped{
+				EnemyId:     enemy.ID,
+				RecipientId: recipientID,
+				Item:        item,
+			},
+		},
+	})
+}
+
+// recordDamage remembers how much of the enemy hp the attacker took
+func (e *Enemy) recordDamage(attackerID string, damage float64) {
+	if attackerID == "" {
+		return
+	}
+	if e.damageDealt == nil {
+		e.damageDealt = make(map[string]float64)
+	}
+	e.damageDealt[attackerID] += damage
+}
+
+// participants are the ids of everyone who damaged the enemy, sorted for stable results
+func (e *Enemy) participants() []string {
+	ids := make([]string, 0, len(e.damageDealt))
+	for id := range e.damageDealt {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func parseEnumName[T ~int32](values map[string]int32, prefix string, name string) (T, bool) {
+	value, ok := values[prefix+strings.ToUpper(name)]
+	return T(value), ok
+}
+
+func (t *ItemTemplate) validate() []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("item %q: %s", t.ID, fmt.Sprintf(format, args...)))
+	}
+
+	if t.ID == "" {
+		fail("id is required")
+	}
+	if t.Name == "" {
+		fail("name is required")
+	}
+
+	slot, ok := parseEnumName[pb.ItemSlot](pb.ItemSlot_value, "ITEM_SLOT_", t.Slot)
+	if !ok || slot == pb.ItemSlot_ITEM_SLOT_NONE {
+		fail("unknown slot %q", t.Slot)
+	}
+	t.slot = slot
+
+	if slot == pb.ItemSlot_ITEM_SLOT_WEAPON {
+		if t.BaseDamage <= 0 {
+			fail("weapons need a positive base_damage")
+		}
+		damageType, ok := parseEnumName[pb.DamageType](pb.DamageType_value, "DAMAGE_TYPE_", t.DamageType)
+		if t.DamageType != "" && !ok {
+			fail("unknown damage type %q", t.DamageType)
+		}
+		t.damageType = damageType
+	}
+	return errs
+}
+
+func (l *LootTable) validate(items map[string]*ItemTemplate) []string {
+	var problems []string
+	if l.DropChance < 0 || l.DropChance > 1 {
+		problems = append(problems, fmt.Sprintf("loot drop_chance must be between 0 and 1, got %g", l.DropChance))
+	}
+	for i := range l.Entries {
+		entry := &l.Entries[i]
+		template, ok := items[entry.ItemID]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("loot references unknown item %q", entry.ItemID))
+		}
+		if entry.Weight <= 0 {
+			problems = append(problems, fmt.Sprintf("loot weight of %q must be positive", entry.ItemID))
+		}
+		entry.template = template
+	}
+
+	l.rarityWeights = make(map[pb.ItemRarity]int, len(l.RarityWeights))
+	total := 0
+	for name, weight := range l.RarityWeights {
+		rarity, ok := parseEnumName[pb.ItemRarity](pb.ItemRarity_value, "ITEM_RARITY_", name)
+		// rollRarity only walks COMMON..LEGENDARY, a weight on anything else would never drop
+		if !ok || rarity < pb.ItemRarity_ITEM_RARITY_COMMON || rarity > pb.ItemRarity_ITEM_RARITY_LEGENDARY || weight < 0 {
+			problems = append(problems, fmt.Sprintf("invalid rarity weight %q: %d", name, weight))
+			continue
+		}
+		l.rarityWeights[rarity] = weight
+		total += weight
+	}
+	if len(l.RarityWeights) > 0 && total == 0 {
+		problems = append(problems, "rarity_weights are all zero")
+	}
+	return problems
+}
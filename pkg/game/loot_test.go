@@ -0,0 +1,130 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLootCatalog(t *testing.T) *EnemyCatalog {
+	items, err := ParseItemCatalog([]byte(`{"items": [
+		{"id": "club", "name": "Club", "slot": "weapon", "base_damage": 10, "damage_growth": 1},
+		{"id": "charm", "name": "Charm", "slot": "trinket", "gold_find": 0.1}
+	]}`))
+	require.NoError(t, err)
+	catalog, err := ParseEnemyCatalog([]byte(`{
+		"archetypes": [{"id": "rat", "name": "Rat", "min_level": 1, "weight": 1, "base_hp": 10, "hp_growth": 1,
+			"gold_multiplier": 1, "exp_multiplier": 1,
+			"loot": {"drop_chance": 1, "entries": [{"item": "club", "weight": 1}, {"item": "charm", "weight": 1}],
+			         "rarity_weights": {"legendary": 1}}}]
+	}`), items)
+	require.NoError(t, err)
+	return catalog
+}
+
+func TestRollLootIsDeterministic(t *testing.T) {
+	table := &LootTable{DropChance: 0.5, Entries: []LootEntry{
+		{ItemID: "club", Weight: 3, template: &ItemTemplate{ID: "club", Name: "Club", slot: pb.ItemSlot_ITEM_SLOT_WEAPON, BaseDamage: 10}},
+		{ItemID: "charm", Weight: 1, template: &ItemTemplate{ID: "charm", Name: "Charm", slot: pb.ItemSlot_ITEM_SLOT_TRINKET, GoldFind: 0.1}},
+	}}
+
+	roll := func(seed uint64) []string {
+		rng := rand.New(rand.NewPCG(seed, seed))
+		var drops []string
+		for i := 0; i < 50; i++ {
+			if item := RollLoot(table, rng); item != nil {
+				drops = append(drops, item.GetItemId()+":"+item.GetRarity().String())
+			} else {
+				drops = append(drops, "-")
+			}
+		}
+		return drops
+	}
+
+	assert.Equal(t, roll(42), roll(42))
+	assert.NotEqual(t, roll(42), roll(43))
+}
+
+func TestRollLootSkipsUnusableEntries(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	charm := &ItemTemplate{ID: "charm", Name: "Charm", slot: pb.ItemSlot_ITEM_SLOT_TRINKET, GoldFind: 0.1}
+
+	// built in code, the catalog never linked the item
+	unlinked := &LootTable{DropChance: 1, Entries: []LootEntry{{ItemID: "club", Weight: 1}}}
+	assert.Nil(t, RollLoot(unlinked, rng))
+	weightless := &LootTable{DropChance: 1, Entries: []LootEntry{{ItemID: "charm", template: charm}}}
+	assert.Nil(t, RollLoot(weightless, rng))
+
+	mixed := &LootTable{DropChance: 1, Entries: []LootEntry{{ItemID: "club", Weight: 5}, {ItemID: "charm", Weight: 1, template: charm}}}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "charm", RollLoot(mixed, rng).GetItemId())
+	}
+}
+
+func TestRarityScalesItemStats(t *testing.T) {
+	template := &ItemTemplate{ID: "club", Name: "Club", slot: pb.ItemSlot_ITEM_SLOT_WEAPON, BaseDamage: 10, DamageGrowth: 2}
+	legendary := template.NewItem(pb.ItemRarity_ITEM_RARITY_LEGENDARY)
+	assert.Equal(t, float32(30), legendary.GetWeapon().GetBaseDamage())
+	assert.Equal(t, float32(6), legendary.GetWeapon().GetDamageGrowth())
+	assert.NoError(t, validateItem(legendary))
+}
+
+func TestLootPolicies(t *testing.T) {
+	newGame := func(policy LootPolicy) (*Game, *pb.Player, *pb.Player) {
+		game := NewGame()
+		game.SeedRandom(1)
+		game.LootPolicy = policy
+		game.ActiveEnemies = 1
+		game.Spawner = NewSpawner(testLootCatalog(t))
+		game.FillEnemies()
+
+		a, b := InitializePlayer("a"), InitializePlayer("b")
+		a.Id, b.Id = "a", "b"
+		game.AddPlayer(a, NewOutbox(nil))
+		game.AddPlayer(b, NewOutbox(nil))
+		return game, a, b
+	}
+
+	t.Run("last hitter", func(t *testing.T) {
+		game, a, b := newGame(LootToLastHitter)
+		require.NoError(t, game.ApplyDamage("", 9, pb.DamageType_DAMAGE_TYPE_PHYSICAL, "a"))
+		require.NoError(t, game.ApplyDamage("", 9, pb.DamageType_DAMAGE_TYPE_PHYSICAL, "b"))
+		assert.Empty(t, a.GetInventory())
+		require.Len(t, b.GetInventory(), 1)
+		assert.Equal(t, pb.ItemRarity_ITEM_RARITY_LEGENDARY, b.GetInventory()[0].GetRarity())
+	})
+
+	t.Run("top damage", func(t *testing.T) {
+		game, a, b := newGame(LootToTopDamage)
+		require.NoError(t, game.ApplyDamage("", 9, pb.DamageType_DAMAGE_TYPE_PHYSICAL, "a"))
+		require.NoError(t, game.ApplyDamage("", 9, pb.DamageType_DAMAGE_TYPE_PHYSICAL, "b"))
+		assert.Len(t, a.GetInventory(), 1, "overkill damage does not count")
+		assert.Empty(t, b.GetInventory())
+	})
+
+	t.Run("round robin", func(t *testing.T) {
+		game, a, b := newGame(LootRoundRobin)
+		for i := 0; i < 4; i++ {
+			require.NoError(t, game.ApplyDamage("", 5, pb.DamageType_DAMAGE_TYPE_PHYSICAL, "a"))
+			require.NoError(t, game.ApplyDamage("", 5, pb.DamageType_DAMAGE_TYPE_PHYSICAL, "b"))
+		}
+		assert.Len(t, a.GetInventory(), 2)
+		assert.Len(t, b.GetInventory(), 2)
+	})
+}
+
+func TestLootRarityWeightsValidation(t *testing.T) {
+	items := map[string]*ItemTemplate{"club": {ID: "club"}}
+	validate := func(weights map[string]int) []string {
+		table := &LootTable{DropChance: 1, Entries: []LootEntry{{ItemID: "club", Weight: 1}}, RarityWeights: weights}
+		return table.validate(items)
+	}
+
+	assert.Empty(t, validate(map[string]int{"rare": 1, "common": 0}))
+	assert.Contains(t, validate(map[string]int{"none": 5}), `invalid rarity weight "none": 5`)
+	assert.Contains(t, validate(map[string]int{"none": 5}), "rarity_weights are all zero", "only droppable tiers count")
+	assert.Contains(t, validate(map[string]int{"common": 0, "epic": 0}), "rarity_weights are all zero")
+}
@@ -0,0 +1,121 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"time"
+)
+
+const (
+	MaxOfflineDuration = 12 * time.Hour
+	// an idle player is assumed to click this often, companions come on top
+	OfflineClicksPerSecond = 1.0
+	// more kills than this in one offline session are not simulated
+	maxOfflineKills = 100000
+)
+
+// OfflineRateTier credits offline time up to Until at Rate of the online speed
+type OfflineRateTier struct {
+	Until time.Duration
+	Rate  float64
+}
+
+// OfflineRateTiers make long absences worth less per hour, the last tier ends at MaxOfflineDuration
+var OfflineRateTiers = []OfflineRateTier{
+	{Until: time.Hour, Rate: 1},
+	{Until: 4 * time.Hour, Rate: 0.5},
+	{Until: MaxOfflineDuration, Rate: 0.2},
+}
+
+// OfflineProgress is what the player earned while offline
+type OfflineProgress struct {
+	Offline  time.Duration
+	Credited time.Duration
+	Kills    int64
+	Gold     int64
+	Exp      int64
+}
+
+// CreditedOfflineTime caps the offline time and applies the diminishing rates to it
+func CreditedOfflineTime(offline time.Duration) time.Duration {
+	var credited float64
+	var from time.Duration
+	for _, tier := range OfflineRateTiers {
+		if offline <= from {
+			break
+		}
+		until := min(offline, tier.Until)
+		credited += float64(until-from) * tier.Rate
+		from = tier.Until
+	}
+	return time.Duration(credited)
+}
+
+// OfflineDPS is the damage per second the player is assumed to deal while away
+func OfflineDPS(player *pb.Player) float64 {
+	dps := PlayerDamage(player) * OfflineClicksPerSecond
+	for _, companion := range player.GetCompanions() {
+		dps += companion.GetDps()
+	}
+	return dps
+}
+
+// CalculateOfflineProgress simulates the player fighting the base enemy curve from his own level
+// for the credited part of the offline time. Damage goes through the usual armor mitigation,
+// every kill is one level higher than the previous one like the spawner does
+func CalculateOfflineProgress(player *pb.Player, offline time.Duration) OfflineProgress {
+	progress := OfflineProgress{Offline: offline, Credited: CreditedOfflineTime(offline)}
+	dps := OfflineDPS(player)
+	if dps <= 0 || progress.Credited <= 0 {
+		return progress
+	}
+
+	remaining := progress.Credited.Seconds()
+	goldFind := PlayerGoldFind(player)
+	souls := SoulRewardMultiplier(player)
+	level := max(player.GetStats().GetLevel(), 1)
+	for progress.Kills < maxOfflineKills {
+		enemy := &Enemy{Armor: ArmorPerLevel * float64(level-1)}
+		effectiveDPS := enemy.MitigateDamage(dps, player.GetEquipment().GetWeapon().GetDamageType())
+		if effectiveDPS <= 0 {
+			break
+		}
+		timeToKill := CalculateEnemyHp(level) / effectiveDPS
+		if timeToKill > remaining {
+			break
+		}
+		remaining -= timeToKill
+
+		gold := BaseGoldPerKill * float64(level) * souls
+		progress.Gold += int64(gold * (1 + goldFind))
+		progress.Exp += int64(BaseExpPerKill * float64(level) * souls)
+		progress.Kills++
+		level++
+	}
+	return progress
+}
+
+// ApplyOfflineProgress credits the player for the time since he was last seen and tells how much he got.
+// Nil means the player is new or was not away long enough to earn anything
+func ApplyOfflineProgress(player *pb.Player, now time.Time) *pb.WelcomeBack {
+	lastSeen := player.GetLastSeen()
+	if lastSeen == 0 || now.Unix() <= lastSeen {
+		return nil
+	}
+
+	progress := CalculateOfflineProgress(player, now.Sub(time.Unix(lastSeen, 0)))
+	player.LastSeen = 0
+	if progress.Kills == 0 {
+		return nil
+	}
+
+	player.Resources.Gold += progress.Gold
+	player.Stats.Experience += progress.Exp
+	return &pb.WelcomeBack{
+		OfflineSeconds:  int64(progress.Offline.Seconds()),
+		CreditedSeconds: int64(progress.Credited.Seconds()),
+		Kills:           progress.Kills,
+		Gold:            progress.Gold,
+		Experience:      progress.Exp,
+		LevelsGained:    levelUp(player),
+	}
+}
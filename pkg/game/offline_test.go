@@ -0,0 +1,54 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreditedOfflineTime(t *testing.T) {
+	assert.Zero(t, CreditedOfflineTime(0))
+	assert.Equal(t, 30*time.Minute, CreditedOfflineTime(30*time.Minute))
+	assert.Equal(t, 2*time.Hour, CreditedOfflineTime(3*time.Hour), "first hour in full, then half")
+	// 1h + 3h*0.5 + 8h*0.2
+	assert.Equal(t, 4*time.Hour+6*time.Minute, CreditedOfflineTime(MaxOfflineDuration))
+	assert.Equal(t, CreditedOfflineTime(MaxOfflineDuration), CreditedOfflineTime(7*24*time.Hour), "offline time is capped")
+}
+
+func TestCalculateOfflineProgress(t *testing.T) {
+	player := InitializePlayer("Tester")
+	player.Equipment.Weapon.BaseDamage = 100
+
+	short := CalculateOfflineProgress(player, time.Minute)
+	long := CalculateOfflineProgress(player, time.Hour)
+	assert.Positive(t, short.Kills)
+	assert.Greater(t, long.Kills, short.Kills)
+	assert.Greater(t, long.Gold, short.Gold)
+	assert.Greater(t, long.Exp, short.Exp)
+
+	weaker := InitializePlayer("Weaker")
+	assert.Less(t, CalculateOfflineProgress(weaker, time.Hour).Gold, long.Gold, "stronger players earn more")
+
+	player.Companions = []*pb.Companion{{Dps: 1000}}
+	assert.Greater(t, CalculateOfflineProgress(player, time.Hour).Kills, long.Kills, "companions keep fighting offline")
+}
+
+func TestApplyOfflineProgress(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	player := InitializePlayer("Tester")
+	assert.Nil(t, ApplyOfflineProgress(player, now), "new players earned nothing")
+
+	player.LastSeen = now.Add(-2 * time.Hour).Unix()
+	gold := player.GetResources().GetGold()
+	back := ApplyOfflineProgress(player, now)
+	require.NotNil(t, back)
+	assert.Equal(t, int64(7200), back.GetOfflineSeconds())
+	assert.Equal(t, int64(5400), back.GetCreditedSeconds())
+	assert.Equal(t, gold+back.GetGold(), player.GetResources().GetGold())
+	assert.Positive(t, back.GetLevelsGained())
+	assert.Zero(t, player.GetLastSeen(), "progress is only credited once")
+	assert.Nil(t, ApplyOfflineProgress(player, now))
+}
@@ -0,0 +1,181 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// OutboxLimit is how many messages may wait for a client after coalescing,
+// a client that falls further behind is disconnected and has to resume
+const OutboxLimit = 256
+
+var (
+	ErrSlowConsumer = errors.New("client does not keep up with the updates")
+	ErrOutboxClosed = errors.New("outbox is closed")
+)
+
+// DeliveryMetrics counts what happened to the messages of every outbox sharing it
+type DeliveryMetrics struct {
+	Sent      atomic.Int64
+	Coalesced atomic.Int64 // replaced by a newer state before they were sent
+	Dropped   atomic.Int64 // thrown away with the outbox of a slow client
+	// clients disconnected because their outbox overflowed
+	SlowConsumers atomic.Int64
+}
+
+func (m *DeliveryMetrics) String() string {
+	return fmt.Sprintf("sent %d, coalesced %d, dropped %d, slow consumers %d",
+		m.Sent.Load(), m.Coalesced.Load(), m.Dropped.Load(), m.SlowConsumers.Load())
+}
+
+type outboxEntry struct {
+	msg *pb.ServerToClient
+	key string
+}
+
+// Outbox is the outbound queue of one stream. The game pushes without ever blocking,
+// the stream pops at its own pace. Messages that only carry the latest state of
+// something (an enemy HP, the player profile...) replace the older queued one
+type Outbox struct {
+	mu       sync.Mutex
+	queue    []outboxEntry
+	ready    chan struct{} // holds a token while there is something to pop or the outbox is closed
+	closed   bool
+	overflow bool
+	metrics  *DeliveryMetrics
+}
+
+// NewOutbox creates an empty outbox, metrics may be nil
+func NewOutbox(metrics *DeliveryMetrics) *Outbox {
+	if metrics == nil {
+		metrics = &DeliveryMetrics{}
+	}
+	return &Outbox{ready: make(chan struct{}, 1), metrics: metrics}
+}
+
+// coalesceKey tells which messages supersede each other, empty means the message always goes out
+func coalesceKey(msg *pb.ServerToClient) string {
+	switch event := msg.GetEvent().(type) {
+	case *pb.ServerToClient_GameStateUpdate:
+		// every hit is shown to the client with its damage and crit, only bare hp changes are replaced
+		if event.GameStateUpdate.LastHit != nil {
+			return ""
+		}
+		return "enemy_hp:" + event.GameStateUpdate.GetEnemyId()
+	case *pb.ServerToClient_PlayerStateUpdate:
+		return "player:" + event.PlayerStateUpdate.GetPlayer().GetId()
+	case *pb.ServerToClient_GuildUpdate:
+		return "guild"
+	case *pb.ServerToClient_TradeUpdate:
+		return "trade:" + event.TradeUpdate.GetId()
+	}
+	return ""
+}
+
+func (o *Outbox) signal() {
+	select {
+	case o.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Push queues the message. It returns ErrSlowConsumer when the message did not fit,
+// the outbox is then closed and emptied, and ErrOutboxClosed for every later push
+func (o *Outbox) Push(msg *pb.ServerToClient) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return ErrOutboxClosed
+	}
+
+	key := coalesceKey(msg)
+	if key != "" {
+		// the newer state goes to the end, so it still comes after everything queued before it
+		i := slices.IndexFunc(o.queue, func(e outboxEntry) bool { return e.key == key })
+		if i >= 0 {
+			o.queue = slices.Delete(o.queue, i, i+1)
+			o.metrics.Coalesced.Add(1)
+		}
+	}
+	o.queue = append(o.queue, outboxEntry{msg: msg, key: key})
+
+	if len(o.queue) > OutboxLimit {
+		o.overflow = true
+		o.closed = true
+		o.metrics.Dropped.Add(int64(len(o.queue)))
+		o.metrics.SlowConsumers.Add(1)
+		o.queue = nil
+		o.signal()
+		return ErrSlowConsumer
+	}
+	o.signal()
+	return nil
+}
+
+// Pop takes the oldest message without waiting, nil if there is none
+func (o *Outbox) Pop() *pb.ServerToClient {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.queue) == 0 {
+		return nil
+	}
+	msg := o.queue[0].msg
+	o.queue[0] = outboxEntry{}
+	o.queue = o.queue[1:]
+	o.metrics.Sent.Add(1)
+	return msg
+}
+
+// Next waits for the oldest message. Once the outbox is closed the rest of the queue
+// is still handed out, then ErrOutboxClosed is returned (ErrSlowConsumer if it overflowed)
+func (o *Outbox) Next(ctx context.Context) (*pb.ServerToClient, error) {
+	for {
+		if msg := o.Pop(); msg != nil {
+			return msg, nil
+		}
+
+		o.mu.Lock()
+		overflow, closed := o.overflow, o.closed
+		o.mu.Unlock()
+		switch {
+		case overflow:
+			return nil, ErrSlowConsumer
+		case closed:
+			return nil, ErrOutboxClosed
+		}
+
+		select {
+		case <-o.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Len is the number of queued messages
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.queue)
+}
+
+// Close stops accepting messages, it is fine to call it more than once
+func (o *Outbox) Close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	o.signal()
+}
+
+func (o *Outbox) Closed() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.closed
+}
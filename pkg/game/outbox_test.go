@@ -0,0 +1,115 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hpUpdate(enemyID string, hp float64) *pb.ServerToClient {
+	return &pb.ServerToClient{Event: &pb.ServerToClient_GameStateUpdate{
+		GameStateUpdate: &pb.GameStateUpdate{EnemyId: enemyID, EnemyCurrentHp: hp},
+	}}
+}
+
+func TestOutboxCoalescesState(t *testing.T) {
+	metrics := &DeliveryMetrics{}
+	outbox := NewOutbox(metrics)
+
+	require.NoError(t, outbox.Push(hpUpdate("a", 90)))
+	require.NoError(t, outbox.Push(hpUpdate("b", 50)))
+	require.NoError(t, outbox.Push(playerLeftMsg("x")))
+	require.NoError(t, outbox.Push(hpUpdate("a", 80)))
+	require.Equal(t, 3, outbox.Len())
+
+	assert.Equal(t, "b", outbox.Pop().GetGameStateUpdate().GetEnemyId())
+	assert.NotNil(t, outbox.Pop().GetPlayerLeft())
+	assert.Equal(t, 80.0, outbox.Pop().GetGameStateUpdate().GetEnemyCurrentHp(), "only the latest hp is sent, after what came before it")
+	assert.Nil(t, outbox.Pop())
+	assert.Equal(t, int64(1), metrics.Coalesced.Load())
+	assert.Equal(t, int64(3), metrics.Sent.Load())
+}
+
+func TestOutboxKeepsEveryHit(t *testing.T) {
+	outbox := NewOutbox(nil)
+	hit := func(hp float64, crit bool) *pb.ServerToClient {
+		msg := hpUpdate("a", hp)
+		msg.GetGameStateUpdate().LastHit = &pb.HitInfo{DamageDealt: 100 - hp, Critical: crit}
+		return msg
+	}
+
+	require.NoError(t, outbox.Push(hit(90, true)))
+	require.NoError(t, outbox.Push(hpUpdate("a", 91)))
+	require.NoError(t, outbox.Push(hit(80, false)))
+	require.NoError(t, outbox.Push(hpUpdate("a", 81)))
+	require.Equal(t, 3, outbox.Len())
+
+	assert.True(t, outbox.Pop().GetGameStateUpdate().GetLastHit().GetCritical())
+	assert.Equal(t, 20.0, outbox.Pop().GetGameStateUpdate().GetLastHit().GetDamageDealt())
+	assert.Equal(t, 81.0, outbox.Pop().GetGameStateUpdate().GetEnemyCurrentHp())
+}
+
+func TestOutboxDisconnectsSlowConsumer(t *testing.T) {
+	metrics := &DeliveryMetrics{}
+	outbox := NewOutbox(metrics)
+
+	for range OutboxLimit - 1 {
+		require.NoError(t, outbox.Push(playerLeftMsg("x")))
+	}
+	// superseded state never overflows the queue
+	require.NoError(t, outbox.Push(hpUpdate("a", 1)))
+	require.NoError(t, outbox.Push(hpUpdate("a", 2)))
+	assert.ErrorIs(t, outbox.Push(playerLeftMsg("x")), ErrSlowConsumer)
+	assert.ErrorIs(t, outbox.Push(playerLeftMsg("x")), ErrOutboxClosed)
+
+	_, err := outbox.Next(context.Background())
+	assert.ErrorIs(t, err, ErrSlowConsumer)
+	assert.Equal(t, int64(OutboxLimit+1), metrics.Dropped.Load())
+	assert.Equal(t, int64(1), metrics.SlowConsumers.Load())
+}
+
+func TestOutboxNext(t *testing.T) {
+	outbox := NewOutbox(nil)
+	got := make(chan *pb.ServerToClient)
+	go func() {
+		msg, err := outbox.Next(context.Background())
+		assert.NoError(t, err)
+		got <- msg
+	}()
+
+	require.NoError(t, outbox.Push(playerLeftMsg("x")))
+	select {
+	case msg := <-got:
+		assert.NotNil(t, msg.GetPlayerLeft())
+	case <-time.After(time.Second):
+		t.Fatal("Next did not wake up")
+	}
+
+	require.NoError(t, outbox.Push(playerLeftMsg("y")))
+	outbox.Close()
+	msg, err := outbox.Next(context.Background())
+	require.NoError(t, err, "a closed outbox still hands out what was queued")
+	assert.Equal(t, "y", msg.GetPlayerLeft().GetPlayerId())
+	_, err = outbox.Next(context.Background())
+	assert.ErrorIs(t, err, ErrOutboxClosed)
+}
+
+func TestSlowPlayerIsDisconnected(t *testing.T) {
+	game := NewGame()
+	player := InitializePlayer("slowpoke")
+	outbox := NewOutbox(nil)
+	game.AddPlayer(player, outbox)
+
+	for range OutboxLimit + 1 {
+		game.SendToPlayer(player.GetId(), playerLeftMsg("x"))
+	}
+	_, err := outbox.Next(context.Background())
+	assert.ErrorIs(t, err, ErrSlowConsumer)
+
+	// the stream ends and the client resumes from the session history
+	require.NotNil(t, game.DetachPlayer(player.GetId(), outbox))
+}
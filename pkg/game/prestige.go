@@ -0,0 +1,83 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"errors"
+	"fmt"
+	"log"
+)
+
+const (
+	// players can be reborn starting from this level
+	MinRebirthLevel = 20
+	// one more soul for every this many levels above MinRebirthLevel
+	LevelsPerExtraSoul = 5
+
+	// what every soul gives, forever
+	SoulDamageBonus = 0.10
+	SoulRewardBonus = 0.05
+)
+
+var ErrRebirthLevelTooLow = errors.New("level is too low to be reborn")
+
+// RebirthSouls is how many souls the player gets if he is reborn now
+func RebirthSouls(player *pb.Player) int64 {
+	level := player.GetStats().GetLevel()
+	if level < MinRebirthLevel {
+		return 0
+	}
+	return 1 + (level-MinRebirthLevel)/LevelsPerExtraSoul
+}
+
+// SoulDamageMultiplier scales the click damage of the player by his souls
+func SoulDamageMultiplier(player *pb.Player) float64 {
+	return 1 + SoulDamageBonus*float64(player.GetPrestige().GetSouls())
+}
+
+// SoulRewardMultiplier scales the gold and exp the player gets for kills by his souls
+func SoulRewardMultiplier(player *pb.Player) float64 {
+	return 1 + SoulRewardBonus*float64(player.GetPrestige().GetSouls())
+}
+
+// Rebirth resets level, stat points, gold and weapon of the player in exchange for souls.
+// Inventory and companions are kept
+func (g *Game) Rebirth(playerID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	session, ok := g.Players[playerID]
+	if !ok {
+		return ErrPlayerNotFound
+	}
+	player := session.Data
+	souls := RebirthSouls(player)
+	if souls == 0 {
+		return fmt.Errorf("%w: need level %d", ErrRebirthLevelTooLow, MinRebirthLevel)
+	}
+	// escrowed gold must come back before the reset, not after it
+	g.cancelTradeOf(playerID)
+
+	fresh := InitializePlayer(player.GetName())
+	player.Stats = fresh.GetStats()
+	player.Resources.Gold = fresh.GetResources().GetGold()
+	player.Equipment.Weapon = starterWeapon()
+	if player.Prestige == nil {
+		player.Prestige = &pb.Prestige{}
+	}
+	player.Prestige.Count++
+	player.Prestige.Souls += souls
+
+	log.Printf("Player %s was reborn for the %d time and got %d souls", player.GetName(), player.Prestige.Count, souls)
+	g.sendPlayerState(player)
+	g.broadcastToAll(&pb.ServerToClient{
+		Event: &pb.ServerToClient_Reborn{
+			Reborn: &pb.Reborn{
+				PlayerId:      player.GetId(),
+				PrestigeCount: player.Prestige.Count,
+				SoulsEarned:   souls,
+				TotalSouls:    player.Prestige.Souls,
+			},
+		},
+	})
+	return nil
+}
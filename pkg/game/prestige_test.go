@@ -0,0 +1,45 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebirth(t *testing.T) {
+	game := NewGame()
+	player := InitializePlayer("Tester")
+	updates := NewOutbox(nil)
+	game.AddPlayer(player, updates)
+
+	player.Stats.Level = MinRebirthLevel - 1
+	assert.ErrorIs(t, game.Rebirth(player.GetId()), ErrRebirthLevelTooLow)
+
+	player.Stats.Level = MinRebirthLevel + 2*LevelsPerExtraSoul
+	player.Resources.Gold = 1e6
+	player.Equipment.Weapon.Level = 10
+	player.Inventory = []*pb.Item{{Id: "kept"}}
+	baseDamage := PlayerDamage(InitializePlayer("Fresh"))
+
+	require.NoError(t, game.Rebirth(player.GetId()))
+	assert.Equal(t, int64(1), player.GetStats().GetLevel())
+	assert.Equal(t, int64(2), player.GetResources().GetGold())
+	assert.Equal(t, int64(1), player.GetEquipment().GetWeapon().GetLevel())
+	assert.Len(t, player.GetInventory(), 1, "inventory survives the rebirth")
+	assert.Equal(t, int64(1), player.GetPrestige().GetCount())
+	assert.Equal(t, int64(3), player.GetPrestige().GetSouls())
+
+	assert.InDelta(t, baseDamage*(1+3*SoulDamageBonus), PlayerDamage(player), 1e-9)
+	assert.InDelta(t, 1+3*SoulRewardBonus, SoulRewardMultiplier(player), 1e-9)
+
+	var reborn *pb.Reborn
+	for updates.Len() > 0 {
+		if r := updates.Pop().GetReborn(); r != nil {
+			reborn = r
+		}
+	}
+	require.NotNil(t, reborn)
+	assert.Equal(t, int64(3), reborn.GetSoulsEarned())
+}
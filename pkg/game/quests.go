@@ -0,0 +1,171 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand/v2"
+	"time"
+)
+
+var (
+	ErrQuestNotFound    = errors.New("quest not found")
+	ErrQuestNotComplete = errors.New("quest is not complete yet")
+	ErrQuestClaimed     = errors.New("quest reward is already claimed")
+)
+
+// QuestSchedule decides when every player gets a new set of quests
+type QuestSchedule struct {
+	// quests are replaced every Interval, counted from the unix epoch shifted by Offset,
+	// so 24h with a 3h offset resets every day at 03:00 UTC
+	Interval time.Duration
+	Offset   time.Duration
+	PerDay   int
+}
+
+var DefaultQuestSchedule = QuestSchedule{Interval: 24 * time.Hour, PerDay: 3}
+
+// Period is the index of the quest period the moment falls in
+func (s QuestSchedule) Period(now time.Time) int64 {
+	return int64(now.Add(-s.Offset).Sub(time.Unix(0, 0)) / s.Interval)
+}
+
+// ResetsAt is when the period ends and the next quests are given out
+func (s QuestSchedule) ResetsAt(period int64) time.Time {
+	return time.Unix(0, 0).Add(time.Duration(period+1)*s.Interval + s.Offset)
+}
+
+// QuestTemplate is a kind of daily objective, rewards grow with the player level
+type QuestTemplate struct {
+	ID           string
+	Description  string // fmt format with the target
+	Event        EventKind
+	Target       int64
+	GoldPerLevel int64
+}
+
+var QuestTemplates = []QuestTemplate{
+	{ID: "kill_small", Description: "Победить %d врагов", Event: EventKill, Target: 25, GoldPerLevel: 20},
+	{ID: "kill_big", Description: "Победить %d врагов", Event: EventKill, Target: 100, GoldPerLevel: 60},
+	{ID: "damage_small", Description: "Нанести %d урона", Event: EventDamageDealt, Target: 5000, GoldPerLevel: 20},
+	{ID: "damage_big", Description: "Нанести %d урона", Event: EventDamageDealt, Target: 50000, GoldPerLevel: 60},
+	{ID: "upgrade", Description: "Улучшить оружие %d раз", Event: EventWeaponUpgraded, Target: 3, GoldPerLevel: 30},
+	{ID: "clicks", Description: "Ударить %d раз", Event: EventClick, Target: 500, GoldPerLevel: 25},
+}
+
+func (g *Game) now() time.Time {
+	if g.Clock != nil {
+		return g.Clock()
+	}
+	return time.Now()
+}
+
+// refreshQuests gives the player new quests if his are from an older period and reports if it did.
+// The same player always gets the same quests for a period
+func (g *Game) refreshQuests(player *pb.Player) bool {
+	if g.Quests.Interval <= 0 {
+		return false
+	}
+	period := g.Quests.Period(g.now())
+	if player.GetQuests() != nil && player.GetQuests().GetPeriod() == period {
+		return false
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(player.GetId()))
+	rng := rand.New(rand.NewPCG(uint64(period), hash.Sum64()))
+
+	level := max(player.GetStats().GetLevel(), 1)
+	quests := &pb.QuestLog{Period: period, ResetsAt: g.Quests.ResetsAt(period).Unix()}
+	for _, i := range rng.Perm(len(QuestTemplates))[:min(g.Quests.PerDay, len(QuestTemplates))] {
+		template := QuestTemplates[i]
+		quests.Quests = append(quests.Quests, &pb.Quest{
+			Id:          template.ID,
+			Description: fmt.Sprintf(template.Description, template.Target),
+			Target:      template.Target,
+			RewardGold:  template.GoldPerLevel * level,
+		})
+	}
+	player.Quests = quests
+	return true
+}
+
+func questTemplate(id string) (QuestTemplate, bool) {
+	for _, template := range QuestTemplates {
+		if template.ID == id {
+			return template, true
+		}
+	}
+	return QuestTemplate{}, false
+}
+
+// trackQuests moves the quests of the player forward, the player hears about it once a quest is done
+func (g *Game) trackQuests(event GameEvent) {
+	session, ok := g.Players[event.PlayerID]
+	if !ok || event.Kind == EventLevelReached {
+		return
+	}
+	player := session.Data
+	changed := g.refreshQuests(player)
+
+	for _, quest := range player.GetQuests().GetQuests() {
+		template, ok := questTemplate(quest.GetId())
+		if !ok || template.Event != event.Kind || quest.GetProgress() >= quest.GetTarget() {
+			continue
+		}
+		quest.Progress = min(quest.GetProgress()+event.Amount, quest.GetTarget())
+		if quest.GetProgress() >= quest.GetTarget() {
+			log.Printf("Player %s completed quest '%s'", player.GetName(), quest.GetDescription())
+			changed = true
+		}
+	}
+	if changed {
+		g.sendPlayerState(player)
+	}
+}
+
+// tickQuests hands out the new quests once the period is over
+func (g *Game) tickQuests() {
+	for _, session := range g.Players {
+		if g.refreshQuests(session.Data) {
+			g.sendPlayerState(session.Data)
+		}
+	}
+}
+
+// ClaimQuest gives the player the reward of a finished quest
+func (g *Game) ClaimQuest(playerID string, questID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	session, ok := g.Players[playerID]
+	if !ok {
+		return ErrPlayerNotFound
+	}
+	player := session.Data
+	if g.refreshQuests(player) {
+		// the quest is from the period that just ended
+		g.sendPlayerState(player)
+		return ErrQuestNotFound
+	}
+
+	for _, quest := range player.GetQuests().GetQuests() {
+		if quest.GetId() != questID {
+			continue
+		}
+		switch {
+		case quest.GetClaimed():
+			return ErrQuestClaimed
+		case quest.GetProgress() < quest.GetTarget():
+			return ErrQuestNotComplete
+		}
+		quest.Claimed = true
+		player.Resources.Gold += quest.GetRewardGold()
+		log.Printf("Player %s claimed %d gold for quest '%s'", player.GetName(), quest.GetRewardGold(), quest.GetDescription())
+		g.sendPlayerState(player)
+		return nil
+	}
+	return ErrQuestNotFound
+}
@@ -0,0 +1,74 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuestSchedule(t *testing.T) {
+	schedule := QuestSchedule{Interval: 24 * time.Hour, Offset: 3 * time.Hour}
+	beforeReset := time.Date(2025, 3, 10, 2, 59, 0, 0, time.UTC)
+	afterReset := time.Date(2025, 3, 10, 3, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, schedule.Period(beforeReset)+1, schedule.Period(afterReset))
+	assert.Equal(t, afterReset, schedule.ResetsAt(schedule.Period(beforeReset)).UTC())
+}
+
+func TestDailyQuests(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	game := NewGame()
+	game.Achievements = nil
+	game.Clock = func() time.Time { return now }
+	game.Quests = QuestSchedule{Interval: 24 * time.Hour, PerDay: len(QuestTemplates)}
+
+	player := InitializePlayer("Tester")
+	game.AddPlayer(player, NewOutbox(nil))
+	require.Len(t, player.GetQuests().GetQuests(), len(QuestTemplates))
+	quest := func(id string) *pb.Quest {
+		for _, q := range player.GetQuests().GetQuests() {
+			if q.GetId() == id {
+				return q
+			}
+		}
+		t.Fatalf("no quest %s", id)
+		return nil
+	}
+
+	player.Resources.Gold = 1000
+	for i := 0; i < 3; i++ {
+		require.NoError(t, game.UpgradeWeapon(player.GetId()))
+	}
+	assert.Equal(t, int64(3), quest("upgrade").GetProgress())
+	game.CreateEnemy(EnemyStats{EnemyMaxHp: 1e9, EnemyLevel: 1}, "Wall", nil)
+	require.NoError(t, game.ApplyDamage("", 1234, pb.DamageType_DAMAGE_TYPE_PHYSICAL, player.GetId()))
+	assert.Equal(t, int64(1234), quest("damage_small").GetProgress())
+
+	assert.ErrorIs(t, game.ClaimQuest(player.GetId(), "kill_small"), ErrQuestNotComplete)
+	assert.ErrorIs(t, game.ClaimQuest(player.GetId(), "nothing"), ErrQuestNotFound)
+	gold := player.GetResources().GetGold()
+	require.NoError(t, game.ClaimQuest(player.GetId(), "upgrade"))
+	assert.Equal(t, gold+quest("upgrade").GetRewardGold(), player.GetResources().GetGold())
+	assert.ErrorIs(t, game.ClaimQuest(player.GetId(), "upgrade"), ErrQuestClaimed)
+
+	now = now.Add(24 * time.Hour)
+	game.Tick(time.Second)
+	assert.Zero(t, quest("upgrade").GetProgress(), "quests reset with the new period")
+	assert.False(t, quest("upgrade").GetClaimed())
+}
+
+func TestQuestsAreStablePerPlayerAndPeriod(t *testing.T) {
+	game := NewGame()
+	game.Clock = func() time.Time { return time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC) }
+	a, b := InitializePlayer("a"), InitializePlayer("a")
+	b.Id = a.Id
+
+	require.True(t, game.refreshQuests(a))
+	require.True(t, game.refreshQuests(b))
+	assert.Len(t, a.GetQuests().GetQuests(), DefaultQuestSchedule.PerDay)
+	assert.Equal(t, a.GetQuests().GetQuests(), b.GetQuests().GetQuests())
+	assert.False(t, game.refreshQuests(a), "nothing changes within the period")
+}
@@ -0,0 +1,112 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"log"
+)
+
+// RewardPolicy decides how the gold and exp of a killed enemy are split between the players who fought it.
+// Players who never hit the enemy get nothing
+type RewardPolicy int
+
+const (
+	// every participant gets a part of the pool proportional to the damage he dealt
+	RewardProportional RewardPolicy = iota
+	// every participant gets the same part of the pool
+	RewardEqual
+	// the whole pool goes to the player who landed the killing blow
+	RewardLastHit
+)
+
+// rewardShares maps every participant to his fraction of the reward pool, fractions add up to 1
+func (g *Game) rewardShares(enemy *Enemy, lastHitterID string) map[string]float64 {
+	participants := enemy.participants()
+	shares := make(map[string]float64, len(participants))
+	if len(participants) == 0 {
+		return shares
+	}
+
+	switch g.RewardPolicy {
+	case RewardEqual:
+		for _, id := range participants {
+			shares[id] = 1 / float64(len(participants))
+		}
+	case RewardLastHit:
+		if _, ok := enemy.damageDealt[lastHitterID]; ok {
+			shares[lastHitterID] = 1
+		}
+	default:
+		var total float64
+		for _, id := range participants {
+			total += enemy.damageDealt[id]
+		}
+		for _, id := range participants {
+			if total > 0 {
+				shares[id] = enemy.damageDealt[id] / total
+			} else {
+				shares[id] = 1 / float64(len(participants))
+			}
+		}
+	}
+	return shares
+}
+
+// awardKill splits the gold and exp of the killed enemy between its participants and announces the split.
+// The pool grows with the number of participants so fighting together does not cost anyone anything
+func (g *Game) awardKill(enemy *Enemy, lastHitterID string) {
+	participants := enemy.participants()
+	if len(participants) == 0 {
+		return
+	}
+
+	poolGold := BaseGoldPerKill * float64(enemy.Level) * rewardMultiplier(enemy.GoldMultiplier) * float64(len(participants))
+	poolExp := BaseExpPerKill * float64(enemy.Level) * rewardMultiplier(enemy.ExpMultiplier) * float64(len(participants))
+
+	shares := g.rewardShares(enemy, lastHitterID)
+	summary := &pb.KillSummary{EnemyId: enemy.ID}
+	for _, id := range participants {
+		session, ok := g.Players[id]
+		if !ok {
+			// left the game before the kill, his part is lost
+			continue
+		}
+		player := session.Data
+		share := shares[id]
+		souls := SoulRewardMultiplier(player)
+
+		gold := int64(poolGold * share * (1 + PlayerGoldFind(player)) * souls)
+		exp := int64(poolExp * share * souls)
+		lastHit := id == lastHitterID
+		if lastHit {
+			bonusGold := int64(poolGold * share * (LastHitGoldBonusMultiplier - 1))
+			bonusExp := int64(poolExp * share * (LastHitExpBonusMultiplier - 1))
+			gold += bonusGold
+			exp += bonusExp
+			log.Printf("Player %s received a last hit bonus: +%d Gold, +%d Exp\n", player.GetName(), bonusGold, bonusExp)
+		}
+		tax := g.payGuildTax(id, gold)
+		gold -= tax
+
+		player.Resources.Gold += gold
+		player.Stats.Experience += exp
+		g.checkForLevelUp(player)
+		g.sendPlayerState(player)
+		g.publish(EventKill, id, 1)
+		g.publish(EventGoldEarned, id, gold)
+
+		summary.Shares = append(summary.Shares, &pb.RewardShare{
+			PlayerId:    id,
+			PlayerName:  player.GetName(),
+			DamageDealt: enemy.damageDealt[id],
+			Share:       share,
+			Gold:        gold,
+			Experience:  exp,
+			LastHit:     lastHit,
+			GuildTax:    tax,
+		})
+	}
+
+	g.broadcastToAll(&pb.ServerToClient{
+		Event: &pb.ServerToClient_KillSummary{KillSummary: summary},
+	})
+}
@@ -0,0 +1,65 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKillRewardsFollowContribution(t *testing.T) {
+	newGame := func(policy RewardPolicy) (*Game, map[string]*pb.Player, *Outbox) {
+		game := NewGame()
+		game.RewardPolicy = policy
+		game.Achievements = nil // their gold rewards would blur the split
+		players := make(map[string]*pb.Player)
+		var updates *Outbox
+		for _, id := range []string{"a", "b", "idle"} {
+			player := InitializePlayer(id)
+			player.Id = id
+			player.Resources.Gold = 0
+			updates = NewOutbox(nil)
+			game.AddPlayer(player, updates)
+			players[id] = player
+		}
+		enemy := game.CreateEnemy(EnemyStats{EnemyMaxHp: 100, EnemyLevel: 1}, "Troll", nil)
+		require.NoError(t, game.ApplyDamage(enemy.ID, 75, pb.DamageType_DAMAGE_TYPE_PHYSICAL, "a"))
+		require.NoError(t, game.ApplyDamage(enemy.ID, 1000, pb.DamageType_DAMAGE_TYPE_PHYSICAL, "b"))
+		return game, players, updates
+	}
+
+	t.Run("proportional", func(t *testing.T) {
+		_, players, updates := newGame(RewardProportional)
+		// pool is 10 gold per participant, b gets 25% of it and the last hit bonus on top
+		assert.Equal(t, int64(15), players["a"].GetResources().GetGold())
+		assert.Equal(t, int64(7), players["b"].GetResources().GetGold())
+		assert.Zero(t, players["idle"].GetResources().GetGold(), "idle players get nothing")
+
+		var summary *pb.KillSummary
+		for updates.Len() > 0 {
+			if s := updates.Pop().GetKillSummary(); s != nil {
+				summary = s
+			}
+		}
+		require.NotNil(t, summary)
+		require.Len(t, summary.GetShares(), 2)
+		assert.Equal(t, "a", summary.GetShares()[0].GetPlayerId())
+		assert.InDelta(t, 0.75, summary.GetShares()[0].GetShare(), 1e-9)
+		assert.InDelta(t, 25.0, summary.GetShares()[1].GetDamageDealt(), 1e-9, "overkill damage does not count")
+		assert.True(t, summary.GetShares()[1].GetLastHit())
+	})
+
+	t.Run("equal", func(t *testing.T) {
+		_, players, _ := newGame(RewardEqual)
+		assert.Equal(t, int64(10), players["a"].GetResources().GetGold())
+		assert.Equal(t, int64(15), players["b"].GetResources().GetGold())
+		assert.Zero(t, players["idle"].GetResources().GetGold())
+	})
+
+	t.Run("last hit", func(t *testing.T) {
+		_, players, _ := newGame(RewardLastHit)
+		assert.Zero(t, players["a"].GetResources().GetGold())
+		assert.Equal(t, int64(30), players["b"].GetResources().GetGold())
+	})
+}
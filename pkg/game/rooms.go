@@ -0,0 +1,253 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// the room players end up in when they do not ask for another one, it is never torn down
+	DefaultRoomID       = "main"
+	DefaultRoomCapacity = 20
+	MaxRoomCapacity     = 100
+	DefaultMaxRooms     = 50
+	// empty rooms live this long so the players creating them have time to join
+	EmptyRoomTTL = time.Minute
+)
+
+var (
+	ErrRoomNotFound = errors.New("room not found")
+	ErrRoomFull     = errors.New("room is full")
+	ErrTooManyRooms = errors.New("too many rooms")
+	// one profile can only have one session
+	ErrAlreadyPlaying = errors.New("player is already playing")
+)
+
+// Room is one Game hosted by the RoomManager with its own enemies and players
+type Room struct {
+	ID       string
+	Name     string
+	Capacity int
+	Game     *Game
+
+	permanent  bool
+	order      int // rooms are listed in creation order
+	players    int // taken slots, guarded by the manager lock
+	emptySince time.Time
+	stop       context.CancelFunc
+}
+
+// RoomManager hosts many games in one process, every room runs its own ticker
+type RoomManager struct {
+	MaxRooms int
+
+	mu      sync.Mutex
+	rooms   map[string]*Room
+	players map[string]*Room // player id -> his room, from JoinPlayer until Leave
+	created int
+	ctx     context.Context
+	newGame func() *Game
+}
+
+// NewRoomManager creates the manager with the default room, newGame sets up the game of every new room.
+// Rooms stop ticking when ctx is done
+func NewRoomManager(ctx context.Context, newGame func() *Game) *RoomManager {
+	m := &RoomManager{
+		MaxRooms: DefaultMaxRooms,
+		rooms:    make(map[string]*Room),
+		players:  make(map[string]*Room),
+		ctx:      ctx,
+		newGame:  newGame,
+	}
+	m.startRoom(DefaultRoomID, "Общая комната", DefaultRoomCapacity, true)
+	return m
+}
+
+func (m *RoomManager) startRoom(id string, name string, capacity int, permanent bool) *Room {
+	ctx, stop := context.WithCancel(m.ctx)
+	room := &Room{
+		ID:         id,
+		Name:       name,
+		Capacity:   capacity,
+		Game:       m.newGame(),
+		permanent:  permanent,
+		order:      m.created,
+		emptySince: time.Now(),
+		stop:       stop,
+	}
+	m.rooms[id] = room
+	m.created++
+	go room.Game.Run(ctx, DefaultTickInterval)
+	return room
+}
+
+// CreateRoom starts a new room, capacity 0 means DefaultRoomCapacity
+func (m *RoomManager) CreateRoom(name string, capacity int) (*Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.rooms) >= m.MaxRooms {
+		return nil, ErrTooManyRooms
+	}
+	if capacity <= 0 {
+		capacity = DefaultRoomCapacity
+	}
+	capacity = min(capacity, MaxRoomCapacity)
+
+	room := m.startRoom(GenerateID(), name, capacity, false)
+	if room.Name == "" {
+		room.Name = "Комната " + room.ID[:8]
+	}
+	log.Printf("Room '%s' (ID: %s) created for %d players", room.Name, room.ID, capacity)
+	return room, nil
+}
+
+// JoinPlayer takes a slot in the room for the player, empty id means the default room.
+// The check and the slot are taken under one lock, so two handshakes of the same profile
+// can not both get in: the second one gets ErrAlreadyPlaying along with the room the player is in.
+// Every successful JoinPlayer must be paired with a Leave once the player is gone
+func (m *RoomManager) JoinPlayer(playerID string, roomID string) (*Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if room, ok := m.players[playerID]; ok {
+		return room, ErrAlreadyPlaying
+	}
+	if roomID == "" {
+		roomID = DefaultRoomID
+	}
+	room, ok := m.rooms[roomID]
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	if room.players >= room.Capacity {
+		return nil, ErrRoomFull
+	}
+	room.players++
+	m.players[playerID] = room
+	return room, nil
+}
+
+// Leave frees the slot taken by JoinPlayer
+func (m *RoomManager) Leave(room *Room, playerID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.players[playerID] != room {
+		return
+	}
+	delete(m.players, playerID)
+	room.players = max(room.players-1, 0)
+	if room.players == 0 {
+		room.emptySince = time.Now()
+	}
+}
+
+// Room finds a room by id, empty id means the default room
+func (m *RoomManager) Room(roomID string) (*Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if roomID == "" {
+		roomID = DefaultRoomID
+	}
+	room, ok := m.rooms[roomID]
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	return room, nil
+}
+
+// Rooms lists the running rooms, oldest first
+func (m *RoomManager) Rooms() []*Room {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].order < rooms[j].order })
+	return rooms
+}
+
+// FindPlayer tells which room the player is in, detached sessions included
+func (m *RoomManager) FindPlayer(playerID string) (*Room, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	room, ok := m.players[playerID]
+	return room, ok
+}
+
+// Sessions copies the profiles of the players in every room, see Game.SnapshotPlayers
+func (m *RoomManager) Sessions() []*pb.Player {
+	var players []*pb.Player
+	for _, room := range m.Rooms() {
+		players = append(players, room.Game.SnapshotPlayers()...)
+	}
+	return players
+}
+
+// CancelTrades cancels the open trades of every room, see Game.CancelTrades
+func (m *RoomManager) CancelTrades() {
+	for _, room := range m.Rooms() {
+		room.Game.CancelTrades()
+	}
+}
+
+func (m *RoomManager) ListRooms() []*pb.RoomInfo {
+	rooms := m.Rooms()
+	infos := make([]*pb.RoomInfo, 0, len(rooms))
+	for _, room := range rooms {
+		infos = append(infos, m.Info(room))
+	}
+	return infos
+}
+
+func (m *RoomManager) Info(room *Room) *pb.RoomInfo {
+	m.mu.Lock()
+	players := room.players
+	m.mu.Unlock()
+
+	return &pb.RoomInfo{
+		Id:       room.ID,
+		Name:     room.Name,
+		Players:  int32(players),
+		Capacity: int32(room.Capacity),
+		Stage:    room.Game.CurrentStage(),
+	}
+}
+
+// Sweep tears down the rooms that stayed empty for EmptyRoomTTL
+func (m *RoomManager) Sweep(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, room := range m.rooms {
+		if room.permanent || room.players > 0 || now.Sub(room.emptySince) < EmptyRoomTTL {
+			continue
+		}
+		room.stop()
+		delete(m.rooms, id)
+		log.Printf("Room '%s' (ID: %s) was empty for too long and is closed", room.Name, room.ID)
+	}
+}
+
+// RunJanitor sweeps empty rooms until ctx is done
+func (m *RoomManager) RunJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.Sweep(now)
+		}
+	}
+}
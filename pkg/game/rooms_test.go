@@ -0,0 +1,163 @@
+package game
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRooms(t *testing.T) *RoomManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return NewRoomManager(ctx, NewGame)
+}
+
+func TestRoomCapacity(t *testing.T) {
+	rooms := newTestRooms(t)
+
+	room, err := rooms.CreateRoom("party", 2)
+	require.NoError(t, err)
+	first := InitializePlayer("first")
+	_, err = rooms.JoinPlayer(first.GetId(), room.ID)
+	require.NoError(t, err)
+	_, err = rooms.JoinPlayer(GenerateID(), room.ID)
+	require.NoError(t, err)
+	_, err = rooms.JoinPlayer(GenerateID(), room.ID)
+	assert.ErrorIs(t, err, ErrRoomFull)
+
+	rooms.Leave(room, first.GetId())
+	_, err = rooms.JoinPlayer(GenerateID(), room.ID)
+	assert.NoError(t, err, "a freed slot can be taken again")
+
+	_, err = rooms.JoinPlayer(GenerateID(), "nope")
+	assert.ErrorIs(t, err, ErrRoomNotFound)
+
+	main, err := rooms.JoinPlayer(first.GetId(), "")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRoomID, main.ID)
+
+	again, err := rooms.JoinPlayer(first.GetId(), room.ID)
+	assert.ErrorIs(t, err, ErrAlreadyPlaying, "one profile gets one session")
+	assert.Equal(t, main, again, "the room the player is already in is returned")
+
+	big, err := rooms.CreateRoom("", 0)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRoomCapacity, big.Capacity)
+	assert.NotEmpty(t, big.Name)
+}
+
+func TestConcurrentJoinsOfOneProfile(t *testing.T) {
+	rooms := newTestRooms(t)
+
+	var joined atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := rooms.JoinPlayer("twin", ""); err == nil {
+				joined.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(1), joined.Load(), "only one handshake may get a session")
+}
+
+func TestRoomsAreSeparate(t *testing.T) {
+	rooms := newTestRooms(t)
+	room, err := rooms.CreateRoom("party", 0)
+	require.NoError(t, err)
+
+	player := InitializePlayer("Alice")
+	_, err = rooms.JoinPlayer(player.GetId(), room.ID)
+	require.NoError(t, err)
+	room.Game.AddPlayer(player, NewOutbox(nil))
+
+	found, ok := rooms.FindPlayer(player.GetId())
+	require.True(t, ok)
+	assert.Equal(t, room.ID, found.ID)
+
+	main, err := rooms.Room("")
+	require.NoError(t, err)
+	assert.False(t, main.Game.HasPlayer(player.GetId()))
+
+	infos := rooms.ListRooms()
+	require.Len(t, infos, 2)
+	assert.Equal(t, DefaultRoomID, infos[0].GetId(), "the default room is the oldest")
+	assert.Equal(t, "party", infos[1].GetName())
+}
+
+func TestSweepEmptyRooms(t *testing.T) {
+	rooms := newTestRooms(t)
+	empty, err := rooms.CreateRoom("empty", 0)
+	require.NoError(t, err)
+	busy, err := rooms.CreateRoom("busy", 0)
+	require.NoError(t, err)
+	_, err = rooms.JoinPlayer("someone", busy.ID)
+	require.NoError(t, err)
+
+	rooms.Sweep(time.Now())
+	_, err = rooms.Room(empty.ID)
+	assert.NoError(t, err, "new rooms get time for players to join")
+
+	rooms.Sweep(time.Now().Add(EmptyRoomTTL))
+	_, err = rooms.Room(empty.ID)
+	assert.ErrorIs(t, err, ErrRoomNotFound)
+	_, err = rooms.Room(busy.ID)
+	assert.NoError(t, err)
+	_, err = rooms.Room(DefaultRoomID)
+	assert.NoError(t, err, "the default room is never closed")
+
+	rooms.Leave(busy, "someone")
+	rooms.Sweep(time.Now().Add(EmptyRoomTTL))
+	_, err = rooms.Room(busy.ID)
+	assert.ErrorIs(t, err, ErrRoomNotFound)
+}
+
+func TestTooManyRooms(t *testing.T) {
+	rooms := newTestRooms(t)
+	rooms.MaxRooms = 2
+
+	_, err := rooms.CreateRoom("one", 0)
+	require.NoError(t, err)
+	_, err = rooms.CreateRoom("two", 0)
+	assert.ErrorIs(t, err, ErrTooManyRooms)
+}
+
+func TestSessionsAreSnapshots(t *testing.T) {
+	rooms := newTestRooms(t)
+	party, err := rooms.CreateRoom("party", 0)
+	require.NoError(t, err)
+	main, err := rooms.Room("")
+	require.NoError(t, err)
+
+	now := time.Unix(1_000_000, 0)
+	main.Game.Clock = func() time.Time { return now }
+	party.Game.Clock = func() time.Time { return now }
+
+	alice, bob := InitializePlayer("Alice"), InitializePlayer("Bob")
+	main.Game.AddPlayer(alice, NewOutbox(nil))
+	updates := NewOutbox(nil)
+	party.Game.AddPlayer(bob, updates)
+	party.Game.DetachPlayer(bob.GetId(), updates)
+	detachedAt := now
+	now = now.Add(time.Minute)
+
+	sessions := rooms.Sessions()
+	require.Len(t, sessions, 2, "detached sessions are saved too")
+	for _, player := range sessions {
+		if player.GetId() == bob.GetId() {
+			assert.Equal(t, detachedAt.Unix(), player.GetLastSeen(), "a detached player is offline since the disconnect")
+		} else {
+			assert.Equal(t, now.Unix(), player.GetLastSeen())
+		}
+		player.Resources.Gold = 1_000_000
+	}
+	assert.Equal(t, int64(2), alice.GetResources().GetGold(), "snapshots are copies")
+}
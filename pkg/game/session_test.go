@@ -0,0 +1,91 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func playerLeftMsg(id string) *pb.ServerToClient {
+	return &pb.ServerToClient{
+		Event: &pb.ServerToClient_PlayerLeft{PlayerLeft: &pb.PlayerLeft{PlayerId: id}},
+	}
+}
+
+func TestResumeSession(t *testing.T) {
+	g := NewGame()
+	player := InitializePlayer("resumer")
+	oldOutbox := NewOutbox(nil)
+	token := g.AddPlayer(player, oldOutbox)
+
+	g.SendToPlayer(player.GetId(), playerLeftMsg("a"))
+	seen := oldOutbox.Pop()
+	require.NotNil(t, seen)
+	assert.Equal(t, int64(1), seen.GetSeq())
+
+	resumed := g.DetachPlayer(player.GetId(), oldOutbox)
+	require.NotNil(t, resumed)
+	assert.True(t, oldOutbox.Closed(), "detached stream outbox should be closed")
+
+	g.SendToPlayer(player.GetId(), playerLeftMsg("b"))
+	g.SendToPlayer(player.GetId(), playerLeftMsg("c"))
+
+	newOutbox := NewOutbox(nil)
+	got, missed, complete, err := g.ResumeSession(token, seen.GetSeq(), newOutbox)
+	require.NoError(t, err)
+	assert.Equal(t, player, got)
+	assert.True(t, complete)
+	require.Len(t, missed, 2)
+	assert.Equal(t, "b", missed[0].GetPlayerLeft().GetPlayerId())
+	assert.Equal(t, "c", missed[1].GetPlayerLeft().GetPlayerId())
+
+	select {
+	case <-resumed:
+	default:
+		t.Fatal("resumed channel should be closed")
+	}
+
+	assert.Nil(t, g.DetachPlayer(player.GetId(), oldOutbox), "stale stream must not detach the resumed session")
+	assert.False(t, g.RemoveDetachedPlayer(player.GetId()))
+}
+
+func TestResumeSessionIncomplete(t *testing.T) {
+	g := NewGame()
+	player := InitializePlayer("slowpoke")
+	updates := NewOutbox(nil)
+	token := g.AddPlayer(player, updates)
+	g.DetachPlayer(player.GetId(), updates)
+
+	for i := 0; i < SessionHistorySize+5; i++ {
+		g.SendToPlayer(player.GetId(), playerLeftMsg("x"))
+	}
+
+	_, missed, complete, err := g.ResumeSession(token, 1, NewOutbox(nil))
+	require.NoError(t, err)
+	assert.False(t, complete)
+	assert.Len(t, missed, SessionHistorySize)
+
+	_, _, _, err = g.ResumeSession("bogus", 0, NewOutbox(nil))
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestSessionTokenOfConnectedAndDetachedPlayers(t *testing.T) {
+	g := NewGame()
+	player := InitializePlayer("twice")
+	updates := NewOutbox(nil)
+	token := g.AddPlayer(player, updates)
+
+	got, ok := g.SessionToken(player.GetId())
+	require.True(t, ok)
+	assert.Equal(t, token, got, "a new stream of the same profile takes a live session over")
+
+	g.DetachPlayer(player.GetId(), updates)
+	got, ok = g.SessionToken(player.GetId())
+	require.True(t, ok)
+	assert.Equal(t, token, got)
+
+	_, ok = g.SessionToken("nobody")
+	assert.False(t, ok)
+}
@@ -0,0 +1,228 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+)
+
+var ErrUnknownOffer = errors.New("unknown shop offer")
+
+// ShopOffer is one thing the shop sells, either an item from the catalog or a timed buff
+type ShopOffer struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"` // item offers default to the item name
+	Price int64  `json:"price"`
+
+	Item string `json:"item"`
+
+	Buff      string  `json:"buff"` // damage or gold_find
+	BuffValue float64 `json:"buff_value"`
+	Duration  int64   `json:"duration"` // seconds
+
+	template *ItemTemplate
+	buffType pb.BuffType
+}
+
+func (o *ShopOffer) ToProto() *pb.ShopOffer {
+	offer := &pb.ShopOffer{
+		Id:              o.ID,
+		Name:            o.Name,
+		Price:           o.Price,
+		BuffType:        o.buffType,
+		BuffValue:       o.BuffValue,
+		DurationSeconds: o.Duration,
+	}
+	if o.template != nil {
+		offer.Item = o.template.NewItem(pb.ItemRarity_ITEM_RARITY_COMMON)
+		offer.Item.Id = ""
+	}
+	return offer
+}
+
+// ShopCatalog is everything the shop sells
+type ShopCatalog struct {
+	Offers []*ShopOffer `json:"offers"`
+}
+
+// LoadShopCatalog reads and validates the shop file, items are sold from the item catalog
+func LoadShopCatalog(path string, items *ItemCatalog) (*ShopCatalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read shop catalog: %w", err)
+	}
+
+	catalog, err := ParseShopCatalog(data, items)
+	if err != nil {
+		return nil, fmt.Errorf("shop catalog %s: %w", path, err)
+	}
+	return catalog, nil
+}
+
+// ParseShopCatalog decodes and validates a shop catalog, items may be nil
+func ParseShopCatalog(data []byte, items *ItemCatalog) (*ShopCatalog, error) {
+	catalog := &ShopCatalog{}
+	if err := decodeCatalog(data, catalog); err != nil {
+		return nil, err
+	}
+	if err := catalog.Validate(items); err != nil {
+		return nil, err
+	}
+	return catalog, nil
+}
+
+// Validate reports every problem in the shop at once
+func (c *ShopCatalog) Validate(itemCatalog *ItemCatalog) error {
+	var errs []error
+	items := itemCatalog.templates()
+	seen := make(map[string]bool, len(c.Offers))
+	for _, offer := range c.Offers {
+		errs = append(errs, offer.validate(items)...)
+		if seen[offer.ID] {
+			errs = append(errs, fmt.Errorf("shop offer %q: duplicate id", offer.ID))
+		}
+		seen[offer.ID] = true
+	}
+	return errors.Join(errs...)
+}
+
+// ShopOffers lists everything the shop sells
+func (g *Game) ShopOffers() []*pb.ShopOffer {
+	g.Lock()
+	defer g.Unlock()
+
+	offers := make([]*pb.ShopOffer, 0, len(g.Shop))
+	for _, offer := range g.Shop {
+		offers = append(offers, offer.ToProto())
+	}
+	return offers
+}
+
+// Buy sells the offer to the player, items go to the inventory and buffs start right away
+func (g *Game) Buy(playerID string, offerID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	session, ok := g.Players[playerID]
+	if !ok {
+		return ErrPlayerNotFound
+	}
+	index := slices.IndexFunc(g.Shop, func(o *ShopOffer) bool { return o.ID == offerID })
+	if index < 0 {
+		return ErrUnknownOffer
+	}
+	offer := g.Shop[index]
+
+	player := session.Data
+	if player.GetResources().GetGold() < offer.Price {
+		return fmt.Errorf("%w: %s costs %d", ErrNotEnoughGold, offer.Name, offer.Price)
+	}
+
+	if offer.template != nil {
+		if err := giveItem(player, offer.template.NewItem(pb.ItemRarity_ITEM_RARITY_COMMON)); err != nil {
+			return err
+		}
+	} else {
+		addBuff(player, offer)
+	}
+	player.Resources.Gold -= offer.Price
+
+	log.Printf("Player %s bought %s for %d gold", player.GetName(), offer.Name, offer.Price)
+	g.sendPlayerState(player)
+	return nil
+}
+
+// addBuff starts the buff of the offer, buying the same buff again extends it
+func addBuff(player *pb.Player, offer *ShopOffer) {
+	for _, buff := range player.GetBuffs() {
+		if buff.GetBuffId() == offer.ID {
+			buff.TimeLeft += float64(offer.Duration)
+			return
+		}
+	}
+	player.Buffs = append(player.Buffs, &pb.Buff{
+		BuffId:   offer.ID,
+		Name:     offer.Name,
+		Type:     offer.buffType,
+		Value:    offer.BuffValue,
+		TimeLeft: float64(offer.Duration),
+	})
+}
+
+// BuffValue sums the active buffs of the player of that type
+func BuffValue(player *pb.Player, buffType pb.BuffType) float64 {
+	var value float64
+	for _, buff := range player.GetBuffs() {
+		if buff.GetType() == buffType {
+			value += buff.GetValue()
+		}
+	}
+	return value
+}
+
+// tickBuffs runs the buff timers down and drops the ones that ran out
+func (g *Game) tickBuffs(seconds float64) {
+	for _, session := range g.Players {
+		player := session.Data
+		if len(player.GetBuffs()) == 0 {
+			continue
+		}
+
+		expired := false
+		for _, buff := range player.GetBuffs() {
+			buff.TimeLeft -= seconds
+			expired = expired || buff.TimeLeft <= 0
+		}
+		if expired {
+			player.Buffs = slices.DeleteFunc(player.Buffs, func(b *pb.Buff) bool { return b.GetTimeLeft() <= 0 })
+			g.sendPlayerState(player)
+		}
+	}
+}
+
+func (o *ShopOffer) validate(items map[string]*ItemTemplate) []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("shop offer %q: %s", o.ID, fmt.Sprintf(format, args...)))
+	}
+
+	if o.ID == "" {
+		fail("id is required")
+	}
+	if o.Price <= 0 {
+		fail("price must be positive, got %d", o.Price)
+	}
+
+	switch {
+	case o.Item != "" && o.Buff != "":
+		fail("offer can sell an item or a buff, not both")
+	case o.Item != "":
+		template, ok := items[o.Item]
+		if !ok {
+			fail("unknown item %q", o.Item)
+			break
+		}
+		o.template = template
+		if o.Name == "" {
+			o.Name = template.Name
+		}
+	case o.Buff != "":
+		buffType, ok := parseEnumName[pb.BuffType](pb.BuffType_value, "BUFF_TYPE_", o.Buff)
+		if !ok || buffType == pb.BuffType_BUFF_TYPE_UNSPECIFIED {
+			fail("unknown buff %q", o.Buff)
+		}
+		o.buffType = buffType
+		if o.BuffValue <= 0 || o.Duration <= 0 {
+			fail("buffs need a positive buff_value and duration")
+		}
+		if o.Name == "" {
+			fail("buffs need a name")
+		}
+	default:
+		fail("offer sells nothing")
+	}
+	return errs
+}
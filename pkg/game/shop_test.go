@@ -0,0 +1,85 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testShopCatalog = `{
+	"offers": [
+		{"id": "buy_sword", "item": "sword", "price": 100},
+		{"id": "rage", "name": "Rage", "price": 10, "buff": "damage", "buff_value": 1, "duration": 10}
+	]
+}`
+
+func testShopItems(t *testing.T) *ItemCatalog {
+	items, err := ParseItemCatalog([]byte(`{"items": [{"id": "sword", "name": "Sword", "slot": "weapon", "base_damage": 10}]}`))
+	require.NoError(t, err)
+	return items
+}
+
+func TestShippedShopCatalogIsValid(t *testing.T) {
+	t.Chdir("../..")
+	items, err := LoadItemCatalog("static/items.json")
+	require.NoError(t, err)
+	shop, err := LoadShopCatalog("static/shop.json", items)
+	require.NoError(t, err)
+	assert.NotEmpty(t, shop.Offers)
+}
+
+func TestShop(t *testing.T) {
+	catalog, err := ParseShopCatalog([]byte(testShopCatalog), testShopItems(t))
+	require.NoError(t, err)
+
+	game := NewGame()
+	game.Shop = catalog.Offers
+	player := InitializePlayer("Tester")
+	game.AddPlayer(player, NewOutbox(nil))
+
+	offers := game.ShopOffers()
+	require.Len(t, offers, 2)
+	assert.Equal(t, "Sword", offers[0].GetName(), "item offers are named after the item")
+	assert.Equal(t, float32(10), offers[0].GetItem().GetWeapon().GetBaseDamage())
+
+	player.Resources.Gold = 120
+	require.NoError(t, game.Buy(player.GetId(), "buy_sword"))
+	require.Len(t, player.GetInventory(), 1)
+	assert.Equal(t, "sword", player.GetInventory()[0].GetItemId())
+	assert.ErrorIs(t, game.Buy(player.GetId(), "buy_sword"), ErrNotEnoughGold)
+	assert.ErrorIs(t, game.Buy(player.GetId(), "nothing"), ErrUnknownOffer)
+
+	damage := PlayerDamage(player)
+	require.NoError(t, game.Buy(player.GetId(), "rage"))
+	require.NoError(t, game.Buy(player.GetId(), "rage"))
+	require.Len(t, player.GetBuffs(), 1, "buying a buff again extends it")
+	assert.Equal(t, 20.0, player.GetBuffs()[0].GetTimeLeft())
+	assert.InDelta(t, 2*damage, PlayerDamage(player), 1e-9)
+
+	game.Tick(15 * time.Second)
+	assert.Len(t, player.GetBuffs(), 1)
+	game.Tick(5 * time.Second)
+	assert.Empty(t, player.GetBuffs())
+	assert.InDelta(t, damage, PlayerDamage(player), 1e-9)
+}
+
+func TestShopOfferValidation(t *testing.T) {
+	_, err := ParseShopCatalog([]byte(`{
+		"offers": [
+			{"id": "ghost", "item": "missing", "price": 1},
+			{"id": "free", "name": "Free", "buff": "damage", "buff_value": 1, "duration": 1},
+			{"id": "odd", "name": "Odd", "price": 1, "buff": "speed", "buff_value": 1, "duration": 1},
+			{"id": "odd", "item": "sword", "price": 1}
+		]
+	}`), testShopItems(t))
+	require.Error(t, err)
+	assert.ErrorContains(t, err, `shop offer "ghost": unknown item "missing"`)
+	assert.ErrorContains(t, err, `shop offer "free": price must be positive`)
+	assert.ErrorContains(t, err, `shop offer "odd": unknown buff "speed"`)
+	assert.ErrorContains(t, err, `shop offer "odd": duplicate id`)
+
+	_, err = ParseShopCatalog([]byte(`{"shop": []}`), nil)
+	assert.ErrorContains(t, err, `unknown field "shop"`)
+}
@@ -0,0 +1,133 @@
+package game
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const DefaultEnemiesPerStage = 10
+
+// Spawner endlessly generates the next enemy from the level curve of the catalog archetypes.
+// Enemies are grouped in stages (zones) of EnemiesPerStage, every enemy is one level
+// above the previous one and a stage is cleared once all of its enemies are dead.
+// The last enemy of every stage is a timed boss.
+// Spawner is not safe for concurrent use, Game calls it under its lock
+type Spawner struct {
+	EnemiesPerStage int64
+	Catalog         *EnemyCatalog
+
+	nextLevel  int64
+	stageKills map[int64]int64 // stage -> enemies of that stage killed so far
+	// only used by Next, a Game rolls the archetypes with its own rng
+	rng *rand.Rand
+}
+
+// NewSpawner creates a spawner for the catalog, nil catalog means DefaultEnemyCatalog
+func NewSpawner(catalog *EnemyCatalog) *Spawner {
+	if catalog == nil {
+		catalog = DefaultEnemyCatalog()
+	}
+	seed := uint64(time.Now().UnixNano())
+	return &Spawner{
+		EnemiesPerStage: DefaultEnemiesPerStage,
+		Catalog:         catalog,
+		nextLevel:       1,
+		stageKills:      make(map[int64]int64),
+		rng:             rand.New(rand.NewPCG(seed, seed)),
+	}
+}
+
+// Next creates the enemy that follows the previously spawned one
+func (s *Spawner) Next() *Enemy {
+	return s.next(s.rng)
+}
+
+// next picks the archetype with rng, so a seeded game spawns the same enemies every time
+func (s *Spawner) next(rng *rand.Rand) *Enemy {
+	level := s.nextLevel
+	s.nextLevel++
+
+	archetype := s.Catalog.ForLevel(level, rng)
+	enemy := archetype.NewEnemy(level, s.StageForLevel(level))
+	if s.IsBossLevel(level) {
+		enemy.makeBoss()
+	}
+	return enemy
+}
+
+// IsBossLevel reports if the enemy of this level closes its stage
+func (s *Spawner) IsBossLevel(level int64) bool {
+	return level > 0 && level%s.EnemiesPerStage == 0
+}
+
+// ResetToStage makes the spawner start the stage over from its first enemy
+func (s *Spawner) ResetToStage(stage int64) {
+	stage = max(stage, 1)
+	s.nextLevel = (stage-1)*s.EnemiesPerStage + 1
+	clear(s.stageKills)
+}
+
+func (s *Spawner) StageForLevel(level int64) int64 {
+	if level < 1 {
+		return 1
+	}
+	return (level-1)/s.EnemiesPerStage + 1
+}
+
+// Stage is the stage the next spawned enemy belongs to
+func (s *Spawner) Stage() int64 {
+	return s.StageForLevel(s.nextLevel)
+}
+
+// RecordKill counts the enemy towards its stage and reports if that stage is now cleared
+func (s *Spawner) RecordKill(enemy *Enemy) bool {
+	s.stageKills[enemy.Stage]++
+	if s.stageKills[enemy.Stage] < s.EnemiesPerStage {
+		return false
+	}
+	delete(s.stageKills, enemy.Stage)
+	return true
+}
+
+// fillEnemies tops the active enemies up from the spawner and returns the new ones
+func (g *Game) fillEnemies() []*Enemy {
+	if g.Spawner == nil {
+		return nil
+	}
+
+	var spawned []*Enemy
+	for len(g.Enemies) < g.ActiveEnemies {
+		enemy := g.Spawner.next(g.rng)
+		g.LastEnemyID = enemy.ID
+		g.Enemies = append(g.Enemies, enemy)
+		spawned = append(spawned, enemy)
+	}
+	return spawned
+}
+
+// FillEnemies spawns enemies until every active slot is taken
+func (g *Game) FillEnemies() []*Enemy {
+	g.Lock()
+	defer g.Unlock()
+	return g.fillEnemies()
+}
+
+// CurrentStage is the lowest stage among the enemies players are fighting
+func (g *Game) CurrentStage() int64 {
+	g.Lock()
+	defer g.Unlock()
+	return g.currentStage()
+}
+
+func (g *Game) currentStage() int64 {
+	var stage int64
+	for _, enemy := range g.activeEnemies() {
+		if stage == 0 || enemy.Stage < stage {
+			stage = enemy.Stage
+		}
+	}
+	if stage == 0 && g.Spawner != nil {
+		stage = g.Spawner.Stage()
+	}
+	return stage
+}
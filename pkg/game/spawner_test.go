@@ -0,0 +1,89 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpawnerFollowsLevelCurve(t *testing.T) {
+	spawner := NewSpawner(nil)
+	spawner.EnemiesPerStage = 3
+
+	for level := int64(1); level <= 7; level++ {
+		enemy := spawner.Next()
+		assert.Equal(t, level, enemy.Level)
+		assert.Equal(t, (level-1)/3+1, enemy.Stage)
+		if level%3 == 0 {
+			assert.True(t, enemy.Boss, "the last enemy of a stage is a boss")
+			assert.Equal(t, CalculateEnemyHp(level)*BossHpMultiplier, enemy.MaxHealth)
+		} else {
+			assert.False(t, enemy.Boss)
+			assert.Equal(t, CalculateEnemyHp(level), enemy.MaxHealth)
+		}
+	}
+}
+
+func TestGameSpawnsEndlesslyAndClearsStages(t *testing.T) {
+	game := NewGame()
+	game.ActiveEnemies = 2
+	game.Spawner = NewSpawner(nil)
+	game.Spawner.EnemiesPerStage = 2
+	game.FillEnemies()
+
+	updates := NewOutbox(nil)
+	player := InitializePlayer("spawner")
+	game.AddPlayer(player, updates)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, game.ApplyDamage("", 1e9, pb.DamageType_DAMAGE_TYPE_PHYSICAL, player.GetId()))
+		assert.Len(t, game.GetActiveEnemies(), 2, "killed enemies should be replaced right away")
+	}
+
+	var cleared []int64
+	for updates.Len() > 0 {
+		if stage := updates.Pop().GetStageCleared(); stage != nil {
+			cleared = append(cleared, stage.GetStage())
+		}
+	}
+	assert.Equal(t, []int64{1, 2}, cleared)
+	assert.Equal(t, int64(3), game.CurrentStage())
+}
+
+func TestCreatedEnemiesFollowSpawnerStages(t *testing.T) {
+	game := NewGame()
+	game.Spawner = NewSpawner(nil)
+	game.Spawner.EnemiesPerStage = 3
+
+	enemy := game.CreateEnemyForLevel(4)
+	assert.Equal(t, int64(2), enemy.Stage)
+	assert.Contains(t, game.Enemies, enemy)
+	assert.Equal(t, int64(3), game.CreateAndPrepareEnemy(7, game.Spawner.Catalog.Archetypes[0]).Stage)
+
+	assert.Equal(t, int64(1), NewGame().CreateEnemyForLevel(DefaultEnemiesPerStage).Stage)
+}
+
+func TestSeededGameSpawnsTheSameEnemies(t *testing.T) {
+	catalog, err := ParseEnemyCatalog([]byte(`{"archetypes": [
+		{"id": "rat", "name": "Rat", "min_level": 1, "weight": 1, "base_hp": 10, "hp_growth": 1, "gold_multiplier": 1, "exp_multiplier": 1},
+		{"id": "bat", "name": "Bat", "min_level": 1, "weight": 1, "base_hp": 10, "hp_growth": 1, "gold_multiplier": 1, "exp_multiplier": 1}
+	]}`), nil)
+	require.NoError(t, err)
+
+	spawn := func(seed uint64) []string {
+		game := NewGame()
+		game.Spawner = NewSpawner(catalog)
+		game.SeedRandom(seed)
+		game.ActiveEnemies = 20
+		var names []string
+		for _, enemy := range game.FillEnemies() {
+			names = append(names, enemy.Name)
+		}
+		return names
+	}
+
+	assert.Equal(t, spawn(7), spawn(7))
+	assert.NotEqual(t, spawn(7), spawn(8))
+}
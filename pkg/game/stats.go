@@ -0,0 +1,56 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"errors"
+	"log"
+)
+
+const (
+	StatPointsPerLevel = 3
+
+	// what one stat point spent on the attribute gives
+	StrengthDamageBonus = 0.05 // of the click damage
+	PrecisionCritChance = 0.005
+	FortuneGoldFind     = 0.02
+)
+
+var (
+	ErrNotEnoughStatPoints = errors.New("not enough stat points")
+	ErrUnknownAttribute    = errors.New("unknown attribute")
+)
+
+// AllocateStatPoints spends unspent stat points of the player on the attribute
+func (g *Game) AllocateStatPoints(playerID string, attribute pb.Attribute, points int64) error {
+	g.Lock()
+	defer g.Unlock()
+
+	session, ok := g.Players[playerID]
+	if !ok {
+		return ErrPlayerNotFound
+	}
+	stats := session.Data.GetStats()
+	if points <= 0 || stats.GetStatPoints() < points {
+		return ErrNotEnoughStatPoints
+	}
+
+	if stats.Attributes == nil {
+		// players saved before attributes existed
+		stats.Attributes = &pb.Attributes{}
+	}
+	switch attribute {
+	case pb.Attribute_ATTRIBUTE_STRENGTH:
+		stats.Attributes.Strength += points
+	case pb.Attribute_ATTRIBUTE_PRECISION:
+		stats.Attributes.Precision += points
+	case pb.Attribute_ATTRIBUTE_FORTUNE:
+		stats.Attributes.Fortune += points
+	default:
+		return ErrUnknownAttribute
+	}
+	stats.StatPoints -= points
+
+	log.Printf("Player %s spent %d stat points on %s", session.Data.GetName(), points, attribute)
+	g.sendPlayerState(session.Data)
+	return nil
+}
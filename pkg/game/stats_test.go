@@ -0,0 +1,49 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevelUpCarriesSurplusExp(t *testing.T) {
+	game := NewGame()
+	player := InitializePlayer("Tester")
+	updates := NewOutbox(nil)
+	game.AddPlayer(player, updates)
+
+	// 100 for level 2, 150 for level 3, 30 left over
+	player.Stats.Experience = 280
+	game.checkForLevelUp(player)
+
+	assert.Equal(t, int64(3), player.GetStats().GetLevel())
+	assert.Equal(t, int64(30), player.GetStats().GetExperience())
+	assert.Equal(t, int64(225), player.GetStats().GetNextLevelExp())
+	assert.Equal(t, int64(2*StatPointsPerLevel), player.GetStats().GetStatPoints())
+
+	require.Equal(t, 1, updates.Len())
+	levelUp := updates.Pop().GetLevelUp()
+	assert.Equal(t, int64(3), levelUp.GetLevel())
+	assert.Equal(t, int64(2), levelUp.GetLevelsGained())
+}
+
+func TestAllocateStatPoints(t *testing.T) {
+	game := NewGame()
+	player := InitializePlayer("Tester")
+	game.AddPlayer(player, NewOutbox(nil))
+	player.Stats.StatPoints = 3
+	damage, goldFind := PlayerDamage(player), PlayerGoldFind(player)
+
+	require.NoError(t, game.AllocateStatPoints(player.GetId(), pb.Attribute_ATTRIBUTE_STRENGTH, 2))
+	require.NoError(t, game.AllocateStatPoints(player.GetId(), pb.Attribute_ATTRIBUTE_FORTUNE, 1))
+	assert.Zero(t, player.GetStats().GetStatPoints())
+	assert.InDelta(t, damage*(1+2*StrengthDamageBonus), PlayerDamage(player), 1e-9)
+	assert.InDelta(t, goldFind+FortuneGoldFind, PlayerGoldFind(player), 1e-9)
+
+	assert.ErrorIs(t, game.AllocateStatPoints(player.GetId(), pb.Attribute_ATTRIBUTE_PRECISION, 1), ErrNotEnoughStatPoints)
+	player.Stats.StatPoints = 1
+	assert.ErrorIs(t, game.AllocateStatPoints(player.GetId(), pb.Attribute_ATTRIBUTE_UNSPECIFIED, 1), ErrUnknownAttribute)
+	assert.ErrorIs(t, game.AllocateStatPoints(player.GetId(), pb.Attribute_ATTRIBUTE_PRECISION, -5), ErrNotEnoughStatPoints)
+}
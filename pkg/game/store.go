@@ -0,0 +1,444 @@
+package game
+
+import (
+	"bytes"
+	pb "clicker/gen/proto"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrPlayerNotFound = errors.New("player not found")
+
+// PlayerStore keeps player profiles between sessions
+type PlayerStore interface {
+	Load(playerID string) (*pb.Player, error)
+	Save(player *pb.Player) error
+	List() ([]*pb.Player, error)
+}
+
+// FilePlayerStore keeps every profile in a separate json file inside Dir
+type FilePlayerStore struct {
+	mu  sync.Mutex
+	Dir string
+	// every profile of Dir, read on the first List and kept up to date by Save
+	cache map[string]*pb.Player
+}
+
+func NewFilePlayerStore(dir string) (*FilePlayerStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create player store directory %s: %w", dir, err)
+	}
+	return &FilePlayerStore{Dir: dir}, nil
+}
+
+func (s *FilePlayerStore) path(playerID string) (string, error) {
+	if playerID == "" || strings.ContainsAny(playerID, `/\.`) {
+		return "", fmt.Errorf("invalid player id %q", playerID)
+	}
+	return filepath.Join(s.Dir, playerID+".json"), nil
+}
+
+func (s *FilePlayerStore) Load(playerID string) (*pb.Player, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(playerID)
+}
+
+func (s *FilePlayerStore) read(playerID string) (*pb.Player, error) {
+	path, err := s.path(playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrPlayerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read player %s: %w", playerID, err)
+	}
+
+	player := &pb.Player{}
+	if err := protojson.Unmarshal(data, player); err != nil {
+		return nil, fmt.Errorf("could not decode player %s: %w", playerID, err)
+	}
+	return player, nil
+}
+
+func (s *FilePlayerStore) Save(player *pb.Player) error {
+	path, err := s.path(player.GetId())
+	if err != nil {
+		return err
+	}
+
+	data, err := protojson.MarshalOptions{Multiline: true}.Marshal(player)
+	if err != nil {
+		return fmt.Errorf("could not encode player %s: %w", player.GetId(), err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// write to a temp file first so a crash never leaves a half written profile
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("could not write player %s: %w", player.GetId(), err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("could not write player %s: %w", player.GetId(), err)
+	}
+	if s.cache != nil {
+		s.cache[player.GetId()] = proto.Clone(player).(*pb.Player)
+	}
+	return nil
+}
+
+// List returns every saved profile, the directory is only read the first time
+func (s *FilePlayerStore) List() ([]*pb.Player, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cache == nil {
+		entries, err := os.ReadDir(s.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("could not list players: %w", err)
+		}
+		cache := make(map[string]*pb.Player, len(entries))
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+				continue
+			}
+			player, err := s.read(strings.TrimSuffix(entry.Name(), ".json"))
+			if err != nil {
+				// one broken profile must not hide all the others
+				log.Printf("Skipping player profile %s: %v", entry.Name(), err)
+				continue
+			}
+			cache[player.GetId()] = player
+		}
+		s.cache = cache
+	}
+
+	players := make([]*pb.Player, 0, len(s.cache))
+	for _, player := range s.cache {
+		players = append(players, proto.Clone(player).(*pb.Player))
+	}
+	return players, nil
+}
+
+// MemoryPlayerStore is a PlayerStore that lives only as long as the process, handy for tests
+type MemoryPlayerStore struct {
+	mu      sync.Mutex
+	players map[string]*pb.Player
+}
+
+func NewMemoryPlayerStore() *MemoryPlayerStore {
+	return &MemoryPlayerStore{players: make(map[string]*pb.Player)}
+}
+
+func (s *MemoryPlayerStore) Load(playerID string) (*pb.Player, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	player, ok := s.players[playerID]
+	if !ok {
+		return nil, ErrPlayerNotFound
+	}
+	return proto.Clone(player).(*pb.Player), nil
+}
+
+func (s *MemoryPlayerStore) Save(player *pb.Player) error {
+	if player.GetId() == "" {
+		return fmt.Errorf("invalid player id %q", player.GetId())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.players[player.GetId()] = proto.Clone(player).(*pb.Player)
+	return nil
+}
+
+func (s *MemoryPlayerStore) List() ([]*pb.Player, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	players := make([]*pb.Player, 0, len(s.players))
+	for _, player := range s.players {
+		players = append(players, proto.Clone(player).(*pb.Player))
+	}
+	return players, nil
+}
+
+// ProfileID is the id of the profile owned by the client holding secret
+func ProfileID(secret string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(secret)).String()
+}
+
+// LoadOrInitializePlayer returns the saved profile of the handshake secret, or a brand new player
+// if there is none. Without a secret the player gets a profile he can not come back to
+func LoadOrInitializePlayer(store PlayerStore, selfInfo *pb.Player) (player *pb.Player, returning bool, err error) {
+	secret := selfInfo.GetSecret()
+	if secret == "" {
+		return InitializePlayer(selfInfo.GetName()), false, nil
+	}
+
+	id := ProfileID(secret)
+	player, err = store.Load(id)
+	if err == nil {
+		return player, true, nil
+	}
+	if !errors.Is(err, ErrPlayerNotFound) {
+		return nil, false, err
+	}
+	player = InitializePlayer(selfInfo.GetName())
+	player.Id = id
+	return player, false, nil
+}
+
+// GuildStore keeps guilds between server restarts
+type GuildStore interface {
+	Save(guild *pb.Guild) error
+	Delete(guildID string) error
+	List() ([]*pb.Guild, error)
+}
+
+// FileGuildStore keeps every guild in a separate json file inside Dir
+type FileGuildStore struct {
+	mu  sync.Mutex
+	Dir string
+}
+
+func NewFileGuildStore(dir string) (*FileGuildStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create guild store directory %s: %w", dir, err)
+	}
+	return &FileGuildStore{Dir: dir}, nil
+}
+
+func (s *FileGuildStore) path(guildID string) (string, error) {
+	if guildID == "" || strings.ContainsAny(guildID, `/\.`) {
+		return "", fmt.Errorf("invalid guild id %q", guildID)
+	}
+	return filepath.Join(s.Dir, guildID+".json"), nil
+}
+
+func (s *FileGuildStore) Save(guild *pb.Guild) error {
+	path, err := s.path(guild.GetId())
+	if err != nil {
+		return err
+	}
+
+	data, err := protojson.MarshalOptions{Multiline: true}.Marshal(guild)
+	if err != nil {
+		return fmt.Errorf("could not encode guild %s: %w", guild.GetId(), err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("could not write guild %s: %w", guild.GetId(), err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("could not write guild %s: %w", guild.GetId(), err)
+	}
+	return nil
+}
+
+func (s *FileGuildStore) Delete(guildID string) error {
+	path, err := s.path(guildID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not delete guild %s: %w", guildID, err)
+	}
+	return nil
+}
+
+func (s *FileGuildStore) List() ([]*pb.Guild, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not list guilds: %w", err)
+	}
+
+	guilds := make([]*pb.Guild, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.Dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("could not read guild %s: %w", entry.Name(), err)
+		}
+		guild := &pb.Guild{}
+		if err := protojson.Unmarshal(data, guild); err != nil {
+			return nil, fmt.Errorf("could not decode guild %s: %w", entry.Name(), err)
+		}
+		guilds = append(guilds, guild)
+	}
+	return guilds, nil
+}
+
+// MemoryGuildStore is a GuildStore that lives only as long as the process, handy for tests
+type MemoryGuildStore struct {
+	mu     sync.Mutex
+	guilds map[string]*pb.Guild
+}
+
+func NewMemoryGuildStore() *MemoryGuildStore {
+	return &MemoryGuildStore{guilds: make(map[string]*pb.Guild)}
+}
+
+func (s *MemoryGuildStore) Save(guild *pb.Guild) error {
+	if guild.GetId() == "" {
+		return fmt.Errorf("invalid guild id %q", guild.GetId())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.guilds[guild.GetId()] = proto.Clone(guild).(*pb.Guild)
+	return nil
+}
+
+func (s *MemoryGuildStore) Delete(guildID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.guilds, guildID)
+	return nil
+}
+
+func (s *MemoryGuildStore) List() ([]*pb.Guild, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	guilds := make([]*pb.Guild, 0, len(s.guilds))
+	for _, guild := range s.guilds {
+		guilds = append(guilds, proto.Clone(guild).(*pb.Guild))
+	}
+	return guilds, nil
+}
+
+var ErrTradeAuditClosed = errors.New("trade audit is closed")
+
+// FileTradeAudit appends every completed trade to Path as a json line.
+// Records are written by a background goroutine so trades never wait for the disk,
+// Close writes whatever is still queued
+type FileTradeAudit struct {
+	Path string
+
+	mu      sync.Mutex
+	pending [][]byte
+	closed  bool
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+func NewFileTradeAudit(path string) (*FileTradeAudit, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("could not create trade audit directory for %s: %w", path, err)
+	}
+	a := &FileTradeAudit{Path: path, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	go a.run()
+	return a, nil
+}
+
+// Record queues the trade for the writer
+func (a *FileTradeAudit) Record(record *pb.TradeRecord) error {
+	data, err := protojson.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("could not encode trade %s: %w", record.GetTradeId(), err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return fmt.Errorf("%w: trade %s is not recorded", ErrTradeAuditClosed, record.GetTradeId())
+	}
+	a.pending = append(a.pending, append(data, '\n'))
+	a.signal()
+	return nil
+}
+
+func (a *FileTradeAudit) signal() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run writes the queued records in batches until the audit is closed
+func (a *FileTradeAudit) run() {
+	defer close(a.done)
+	for range a.wake {
+		a.mu.Lock()
+		batch, closed := a.pending, a.closed
+		a.pending = nil
+		a.mu.Unlock()
+
+		if len(batch) > 0 {
+			if err := a.write(batch); err != nil {
+				log.Printf("Could not write %d trade records: %v", len(batch), err)
+			}
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+func (a *FileTradeAudit) write(batch [][]byte) error {
+	file, err := os.OpenFile(a.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open trade audit: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(bytes.Join(batch, nil)); err != nil {
+		return fmt.Errorf("could not write trade audit: %w", err)
+	}
+	return nil
+}
+
+// Close stops taking records and waits until the queued ones are written
+func (a *FileTradeAudit) Close() {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		a.signal()
+	}
+	a.mu.Unlock()
+	<-a.done
+}
+
+// MemoryTradeAudit keeps the trade records in memory, handy for tests
+type MemoryTradeAudit struct {
+	mu      sync.Mutex
+	records []*pb.TradeRecord
+}
+
+func (a *MemoryTradeAudit) Record(record *pb.TradeRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records = append(a.records, proto.Clone(record).(*pb.TradeRecord))
+	return nil
+}
+
+func (a *MemoryTradeAudit) Records() []*pb.TradeRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.records)
+}
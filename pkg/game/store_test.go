@@ -0,0 +1,118 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestFilePlayerStore(t *testing.T) {
+	store, err := NewFilePlayerStore(t.TempDir())
+	require.NoError(t, err)
+
+	player := InitializePlayer("tester")
+	player.Resources.Gold = 1234
+	player.Equipment.Weapon.Level = 7
+	require.NoError(t, store.Save(player))
+
+	loaded, err := store.Load(player.GetId())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1234), loaded.GetResources().GetGold())
+	assert.Equal(t, int64(7), loaded.GetEquipment().GetWeapon().GetLevel())
+
+	require.NoError(t, os.WriteFile(filepath.Join(store.Dir, "broken.json"), []byte("{"), 0o644))
+	players, err := store.List()
+	require.NoError(t, err, "broken profiles are skipped")
+	assert.Len(t, players, 1)
+
+	player.Resources.Gold = 4321
+	require.NoError(t, store.Save(player))
+	players, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, players, 1)
+	assert.Equal(t, int64(4321), players[0].GetResources().GetGold(), "saves reach the listed profiles")
+	players[0].Resources.Gold = 0
+	players, err = store.List()
+	require.NoError(t, err)
+	assert.Equal(t, int64(4321), players[0].GetResources().GetGold(), "listed profiles are copies")
+
+	_, err = store.Load(GenerateID())
+	assert.ErrorIs(t, err, ErrPlayerNotFound)
+
+	_, err = store.Load("../escape")
+	assert.Error(t, err)
+}
+
+func TestLoadOrInitializePlayer(t *testing.T) {
+	store := NewMemoryPlayerStore()
+	saved := InitializePlayer("veteran")
+	saved.Id = ProfileID("veteran's secret")
+	saved.Stats.Level = 12
+	require.NoError(t, store.Save(saved))
+
+	t.Run("by secret", func(t *testing.T) {
+		player, returning, err := LoadOrInitializePlayer(store, &pb.Player{Name: "whoever", Secret: "veteran's secret"})
+		require.NoError(t, err)
+		assert.True(t, returning)
+		assert.Equal(t, int64(12), player.GetStats().GetLevel())
+		assert.Empty(t, player.GetSecret())
+	})
+
+	t.Run("name or id do not claim a profile", func(t *testing.T) {
+		for _, selfInfo := range []*pb.Player{
+			{Name: "veteran"},
+			{Id: saved.GetId()},
+			{Name: "veteran", Secret: "guess"},
+		} {
+			player, returning, err := LoadOrInitializePlayer(store, selfInfo)
+			require.NoError(t, err)
+			assert.False(t, returning)
+			assert.NotEqual(t, saved.GetId(), player.GetId())
+		}
+	})
+
+	t.Run("new player", func(t *testing.T) {
+		player, returning, err := LoadOrInitializePlayer(store, &pb.Player{Name: "rookie", Secret: "rookie's secret"})
+		require.NoError(t, err)
+		assert.False(t, returning)
+		assert.Equal(t, int64(1), player.GetStats().GetLevel())
+		assert.Equal(t, ProfileID("rookie's secret"), player.GetId(), "the new profile is found by the secret next time")
+	})
+
+	t.Run("saved copy is not shared", func(t *testing.T) {
+		player, _, err := LoadOrInitializePlayer(store, &pb.Player{Secret: "veteran's secret"})
+		require.NoError(t, err)
+		player.Resources.Gold = 999
+
+		again, err := store.Load(saved.GetId())
+		require.NoError(t, err)
+		assert.NotEqual(t, int64(999), again.GetResources().GetGold())
+	})
+}
+
+func TestFileTradeAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "trades.log")
+	audit, err := NewFileTradeAudit(path)
+	require.NoError(t, err)
+
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, audit.Record(&pb.TradeRecord{TradeId: id}))
+	}
+	audit.Close()
+	audit.Close()
+	assert.ErrorIs(t, audit.Record(&pb.TradeRecord{TradeId: "late"}), ErrTradeAuditClosed)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 3, "everything queued before Close is written")
+	record := &pb.TradeRecord{}
+	require.NoError(t, protojson.Unmarshal([]byte(lines[2]), record))
+	assert.Equal(t, "c", record.GetTradeId())
+}
@@ -0,0 +1,38 @@
+package game
+
+import (
+	"context"
+	"time"
+)
+
+const DefaultTickInterval = time.Second
+
+// Run drives everything in the game that happens over time until ctx is done
+func (g *Game) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			g.Tick(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+// Tick advances the game by elapsed time
+func (g *Game) Tick(elapsed time.Duration) {
+	g.Lock()
+	defer g.Unlock()
+	g.regenerateEnemies(elapsed.Seconds())
+	g.tickBosses(elapsed.Seconds())
+	g.tickBuffs(elapsed.Seconds())
+	g.tickQuests()
+	g.tickTrades()
+	g.refreshGuildBonuses()
+	g.attackWithCompanions(elapsed.Seconds())
+}
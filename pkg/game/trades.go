@@ -0,0 +1,381 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// trades nobody touched for this long are cancelled and the goods returned
+const TradeTimeout = 2 * time.Minute
+
+var (
+	ErrTradeNotFound  = errors.New("trade not found")
+	ErrAlreadyTrading = errors.New("player is already trading")
+	ErrTradeWithSelf  = errors.New("can not trade with yourself")
+	ErrTradeLocked    = errors.New("offer is already locked")
+	ErrTradeNotLocked = errors.New("both offers must be locked first")
+	ErrInvalidOffer   = errors.New("invalid trade offer")
+	ErrPartnerOffline = errors.New("trade partner is not connected")
+)
+
+// TradeAudit keeps a record of every completed trade
+type TradeAudit interface {
+	Record(record *pb.TradeRecord) error
+}
+
+// Trade is an exchange of gold and items between two players of the game.
+// Locked offers are kept in escrow, so nothing can be spent twice while the trade is open
+type Trade struct {
+	ID        string
+	sides     [2]*tradeSide // initiator, partner
+	expiresAt time.Time
+}
+
+type tradeSide struct {
+	playerID string
+	gold     int64
+	itemIDs  []string
+	locked   bool
+	// goods taken from the player while the offer is locked, they always go back to owner
+	owner       *pb.Player
+	escrowGold  int64
+	escrowItems []*pb.Item
+	confirmed   bool
+}
+
+// findTrade returns the trade with the side of the player and the other one
+func (g *Game) findTrade(tradeID string, playerID string) (*Trade, *tradeSide, *tradeSide, error) {
+	trade, ok := g.trades[tradeID]
+	if !ok {
+		return nil, nil, nil, ErrTradeNotFound
+	}
+	switch playerID {
+	case trade.sides[0].playerID:
+		return trade, trade.sides[0], trade.sides[1], nil
+	case trade.sides[1].playerID:
+		return trade, trade.sides[1], trade.sides[0], nil
+	}
+	return nil, nil, nil, ErrTradeNotFound
+}
+
+func (g *Game) tradeOf(playerID string) *Trade {
+	for _, trade := range g.trades {
+		if trade.sides[0].playerID == playerID || trade.sides[1].playerID == playerID {
+			return trade
+		}
+	}
+	return nil
+}
+
+// ProposeTrade opens an empty trade between two connected players
+func (g *Game) ProposeTrade(playerID string, partnerID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if _, ok := g.Players[playerID]; !ok {
+		return ErrPlayerNotFound
+	}
+	if playerID == partnerID {
+		return ErrTradeWithSelf
+	}
+	partner, ok := g.Players[partnerID]
+	if !ok {
+		return ErrPlayerNotFound
+	}
+	if !partner.connected {
+		return ErrPartnerOffline
+	}
+	if g.tradeOf(playerID) != nil || g.tradeOf(partnerID) != nil {
+		return ErrAlreadyTrading
+	}
+
+	trade := &Trade{
+		ID:        GenerateID(),
+		sides:     [2]*tradeSide{{playerID: playerID}, {playerID: partnerID}},
+		expiresAt: g.now().Add(TradeTimeout),
+	}
+	g.trades[trade.ID] = trade
+	g.sendTrade(trade, pb.TradeStatus_TRADE_STATUS_OPEN)
+	log.Printf("Player %s offered a trade to %s (ID: %s)", playerID, partnerID, trade.ID)
+	return nil
+}
+
+// OfferTrade sets what the player gives, it works as a counter offer as well.
+// The other side has to agree to the new terms, so both offers are unlocked
+func (g *Game) OfferTrade(playerID string, tradeID string, gold int64, itemIDs []string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	trade, side, _, err := g.findTrade(tradeID, playerID)
+	if err != nil {
+		return err
+	}
+	player := g.Players[playerID].Data
+	if gold < 0 {
+		return fmt.Errorf("%w: negative gold", ErrInvalidOffer)
+	}
+	if side.locked {
+		// the escrowed gold still belongs to the player
+		if gold > player.GetResources().GetGold()+side.escrowGold {
+			return fmt.Errorf("%w: offer is %d, the player has %d", ErrNotEnoughGold, gold, player.GetResources().GetGold()+side.escrowGold)
+		}
+	} else if gold > player.GetResources().GetGold() {
+		return fmt.Errorf("%w: offer is %d, the player has %d", ErrNotEnoughGold, gold, player.GetResources().GetGold())
+	}
+	seen := make(map[string]bool, len(itemIDs))
+	for _, id := range itemIDs {
+		if seen[id] {
+			return fmt.Errorf("%w: item %s is offered twice", ErrInvalidOffer, id)
+		}
+		seen[id] = true
+		inEscrow := slices.ContainsFunc(side.escrowItems, func(item *pb.Item) bool { return item.GetId() == id })
+		if !inEscrow && !slices.ContainsFunc(player.GetInventory(), func(item *pb.Item) bool { return item.GetId() == id }) {
+			return fmt.Errorf("%w: %s", ErrItemNotFound, id)
+		}
+	}
+
+	g.unlockTrade(trade)
+	side.gold = gold
+	side.itemIDs = slices.Clone(itemIDs)
+	trade.expiresAt = g.now().Add(TradeTimeout)
+	g.sendTrade(trade, pb.TradeStatus_TRADE_STATUS_OPEN)
+	return nil
+}
+
+// LockTrade moves the offered goods of the player into escrow
+func (g *Game) LockTrade(playerID string, tradeID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	trade, side, _, err := g.findTrade(tradeID, playerID)
+	if err != nil {
+		return err
+	}
+	if side.locked {
+		return ErrTradeLocked
+	}
+	player := g.Players[playerID].Data
+	if player.GetResources().GetGold() < side.gold {
+		return fmt.Errorf("%w: offer is %d, the player has %d", ErrNotEnoughGold, side.gold, player.GetResources().GetGold())
+	}
+
+	items := make([]*pb.Item, 0, len(side.itemIDs))
+	for _, id := range side.itemIDs {
+		item, err := takeItem(player, id)
+		if err != nil {
+			// equipped or gone since the offer, put back what was taken
+			player.Inventory = append(player.Inventory, items...)
+			return fmt.Errorf("%w: %s", err, id)
+		}
+		items = append(items, item)
+	}
+	player.Resources.Gold -= side.gold
+	side.owner = player
+	side.escrowGold = side.gold
+	side.escrowItems = items
+	side.locked = true
+	trade.expiresAt = g.now().Add(TradeTimeout)
+
+	g.sendPlayerState(player)
+	g.sendTrade(trade, pb.TradeStatus_TRADE_STATUS_OPEN)
+	return nil
+}
+
+// ConfirmTrade agrees to the locked offers, the second confirmation swaps the escrows
+func (g *Game) ConfirmTrade(playerID string, tradeID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	trade, side, other, err := g.findTrade(tradeID, playerID)
+	if err != nil {
+		return err
+	}
+	if !side.locked || !other.locked {
+		return ErrTradeNotLocked
+	}
+	side.confirmed = true
+	trade.expiresAt = g.now().Add(TradeTimeout)
+	if !other.confirmed {
+		g.sendTrade(trade, pb.TradeStatus_TRADE_STATUS_OPEN)
+		return nil
+	}
+	return g.completeTrade(trade)
+}
+
+func (g *Game) completeTrade(trade *Trade) error {
+	initiator, partner := trade.sides[0], trade.sides[1]
+	initiatorData, partnerData := g.Players[initiator.playerID].Data, g.Players[partner.playerID].Data
+
+	for _, pair := range [][2]*tradeSide{{initiator, partner}, {partner, initiator}} {
+		receiver := g.Players[pair[0].playerID].Data
+		if len(receiver.GetInventory())+len(pair[1].escrowItems) > MaxInventorySize {
+			initiator.confirmed, partner.confirmed = false, false
+			g.sendTrade(trade, pb.TradeStatus_TRADE_STATUS_OPEN)
+			return fmt.Errorf("%w: %s can not take %d more items", ErrInventoryFull, receiver.GetName(), len(pair[1].escrowItems))
+		}
+	}
+
+	record := &pb.TradeRecord{
+		TradeId:     trade.ID,
+		CompletedAt: g.now().Unix(),
+		Initiator:   g.tradeOfferProto(initiator),
+		Partner:     g.tradeOfferProto(partner),
+	}
+
+	initiatorData.Resources.Gold += partner.escrowGold
+	initiatorData.Inventory = append(initiatorData.Inventory, partner.escrowItems...)
+	partnerData.Resources.Gold += initiator.escrowGold
+	partnerData.Inventory = append(partnerData.Inventory, initiator.escrowItems...)
+	for _, side := range trade.sides {
+		side.owner, side.escrowGold, side.escrowItems, side.locked = nil, 0, nil, false
+	}
+	delete(g.trades, trade.ID)
+
+	if g.TradeAudit != nil {
+		if err := g.TradeAudit.Record(record); err != nil {
+			log.Printf("Could not record trade %s: %v", trade.ID, err)
+		}
+	}
+	log.Printf("Trade %s completed: %s gave %d gold and %d items, %s gave %d gold and %d items", trade.ID,
+		initiatorData.GetName(), record.Initiator.GetGold(), len(record.Initiator.GetItems()),
+		partnerData.GetName(), record.Partner.GetGold(), len(record.Partner.GetItems()))
+
+	g.sendPlayerState(initiatorData)
+	g.sendPlayerState(partnerData)
+	completed := g.tradeProto(trade, pb.TradeStatus_TRADE_STATUS_COMPLETED)
+	completed.Initiator, completed.Partner = record.Initiator, record.Partner
+	g.sendTradeProto(trade, completed)
+	return nil
+}
+
+// CancelTrade ends the trade and gives the escrowed goods back
+func (g *Game) CancelTrade(playerID string, tradeID string) error {
+	g.Lock()
+	defer g.Unlock()
+
+	trade, _, _, err := g.findTrade(tradeID, playerID)
+	if err != nil {
+		return err
+	}
+	g.endTrade(trade, pb.TradeStatus_TRADE_STATUS_CANCELLED)
+	return nil
+}
+
+// cancelTradeOf ends the trade of a player leaving the game
+func (g *Game) cancelTradeOf(playerID string) {
+	if trade := g.tradeOf(playerID); trade != nil {
+		g.endTrade(trade, pb.TradeStatus_TRADE_STATUS_CANCELLED)
+	}
+}
+
+// CancelTrades ends every open trade and gives the escrowed goods back,
+// the server does it before saving the players on shutdown
+func (g *Game) CancelTrades() {
+	g.Lock()
+	defer g.Unlock()
+	for _, trade := range g.trades {
+		g.endTrade(trade, pb.TradeStatus_TRADE_STATUS_CANCELLED)
+	}
+}
+
+// escrowOf returns the goods the player has in escrow
+func (g *Game) escrowOf(playerID string) (int64, []*pb.Item) {
+	trade := g.tradeOf(playerID)
+	if trade == nil {
+		return 0, nil
+	}
+	for _, side := range trade.sides {
+		if side.playerID == playerID {
+			return side.escrowGold, side.escrowItems
+		}
+	}
+	return 0, nil
+}
+
+// tickTrades cancels the trades nobody touched for TradeTimeout
+func (g *Game) tickTrades() {
+	now := g.now()
+	for _, trade := range g.trades {
+		if !now.Before(trade.expiresAt) {
+			log.Printf("Trade %s expired", trade.ID)
+			g.endTrade(trade, pb.TradeStatus_TRADE_STATUS_EXPIRED)
+		}
+	}
+}
+
+func (g *Game) endTrade(trade *Trade, status pb.TradeStatus) {
+	g.unlockTrade(trade)
+	delete(g.trades, trade.ID)
+	g.sendTrade(trade, status)
+}
+
+// unlockTrade returns the escrowed goods to both sides and drops the confirmations
+func (g *Game) unlockTrade(trade *Trade) {
+	for _, side := range trade.sides {
+		side.confirmed = false
+		if !side.locked {
+			continue
+		}
+		side.locked = false
+		// the profile the goods were taken from gets them back even if the session is gone,
+		// escrowed items always come back, even over the inventory limit
+		side.owner.Resources.Gold += side.escrowGold
+		side.owner.Inventory = append(side.owner.Inventory, side.escrowItems...)
+		side.owner, side.escrowGold, side.escrowItems = nil, 0, nil
+		if session, ok := g.Players[side.playerID]; ok {
+			g.sendPlayerState(session.Data)
+		}
+	}
+}
+
+func (g *Game) tradeOfferProto(side *tradeSide) *pb.TradeOffer {
+	offer := &pb.TradeOffer{
+		PlayerId:  side.playerID,
+		Gold:      side.gold,
+		Locked:    side.locked,
+		Confirmed: side.confirmed,
+	}
+	session, ok := g.Players[side.playerID]
+	if !ok {
+		return offer
+	}
+	offer.PlayerName = session.Data.GetName()
+	if side.locked {
+		offer.Items = slices.Clone(side.escrowItems)
+		return offer
+	}
+	for _, id := range side.itemIDs {
+		for _, item := range session.Data.GetInventory() {
+			if item.GetId() == id {
+				offer.Items = append(offer.Items, item)
+			}
+		}
+	}
+	return offer
+}
+
+func (g *Game) tradeProto(trade *Trade, status pb.TradeStatus) *pb.Trade {
+	return &pb.Trade{
+		Id:        trade.ID,
+		Initiator: g.tradeOfferProto(trade.sides[0]),
+		Partner:   g.tradeOfferProto(trade.sides[1]),
+		Status:    status,
+		TimeLeft:  max(trade.expiresAt.Sub(g.now()).Seconds(), 0),
+	}
+}
+
+func (g *Game) sendTrade(trade *Trade, status pb.TradeStatus) {
+	g.sendTradeProto(trade, g.tradeProto(trade, status))
+}
+
+func (g *Game) sendTradeProto(trade *Trade, update *pb.Trade) {
+	for _, side := range trade.sides {
+		g.sendToPlayer(side.playerID, &pb.ServerToClient{
+			Event: &pb.ServerToClient_TradeUpdate{TradeUpdate: update},
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package game
+
+import (
+	pb "clicker/gen/proto"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTradeGame(t *testing.T) (*Game, *pb.Player, *pb.Player, *MemoryTradeAudit) {
+	game := NewGame()
+	audit := &MemoryTradeAudit{}
+	game.TradeAudit = audit
+	alice := InitializePlayer("alice")
+	alice.Id = "alice"
+	alice.Resources.Gold = 100
+	bob := InitializePlayer("bob")
+	bob.Id = "bob"
+	bob.Resources.Gold = 50
+	game.AddPlayer(alice, NewOutbox(nil))
+	game.AddPlayer(bob, NewOutbox(nil))
+	require.NoError(t, game.GiveItem("alice", &pb.Item{Id: "sword", Name: "Sword", Slot: pb.ItemSlot_ITEM_SLOT_WEAPON, Weapon: &pb.Weapon{Name: "Sword", BaseDamage: 5, Level: 1}}))
+	require.NoError(t, game.GiveItem("bob", &pb.Item{Id: "coin", Name: "Coin", Slot: pb.ItemSlot_ITEM_SLOT_TRINKET, GoldFind: 0.1}))
+	return game, alice, bob, audit
+}
+
+func openTrade(t *testing.T, game *Game) string {
+	require.NoError(t, game.ProposeTrade("alice", "bob"))
+	trade := game.tradeOf("alice")
+	require.NotNil(t, trade)
+	return trade.ID
+}
+
+func TestTradeCompletes(t *testing.T) {
+	game, alice, bob, audit := newTradeGame(t)
+	id := openTrade(t, game)
+	assert.ErrorIs(t, game.ProposeTrade("bob", "alice"), ErrAlreadyTrading)
+
+	require.NoError(t, game.OfferTrade("alice", id, 30, []string{"sword"}))
+	require.NoError(t, game.OfferTrade("bob", id, 0, []string{"coin"}))
+	assert.ErrorIs(t, game.ConfirmTrade("alice", id), ErrTradeNotLocked)
+
+	require.NoError(t, game.LockTrade("alice", id))
+	assert.Equal(t, int64(70), alice.GetResources().GetGold(), "locked gold goes into escrow")
+	assert.Empty(t, alice.GetInventory())
+	require.NoError(t, game.LockTrade("bob", id))
+	require.NoError(t, game.ConfirmTrade("alice", id))
+	require.NoError(t, game.ConfirmTrade("bob", id))
+
+	assert.Equal(t, int64(70), alice.GetResources().GetGold())
+	assert.Equal(t, int64(80), bob.GetResources().GetGold())
+	require.Len(t, alice.GetInventory(), 1)
+	assert.Equal(t, "coin", alice.GetInventory()[0].GetId())
+	require.Len(t, bob.GetInventory(), 1)
+	assert.Equal(t, "sword", bob.GetInventory()[0].GetId())
+	assert.Nil(t, game.tradeOf("alice"))
+
+	records := audit.Records()
+	require.Len(t, records, 1)
+	assert.Equal(t, id, records[0].GetTradeId())
+	assert.Equal(t, int64(30), records[0].GetInitiator().GetGold())
+	assert.Equal(t, "coin", records[0].GetPartner().GetItems()[0].GetId())
+}
+
+func TestCounterOfferUnlocksBothSides(t *testing.T) {
+	game, alice, _, audit := newTradeGame(t)
+	id := openTrade(t, game)
+
+	require.NoError(t, game.OfferTrade("alice", id, 30, nil))
+	require.NoError(t, game.LockTrade("alice", id))
+	require.NoError(t, game.OfferTrade("bob", id, 10, nil))
+
+	trade := game.tradeOf("alice")
+	assert.False(t, trade.sides[0].locked, "new terms have to be agreed again")
+	assert.Equal(t, int64(100), alice.GetResources().GetGold(), "escrow is returned")
+
+	assert.ErrorIs(t, game.OfferTrade("alice", id, 1000, nil), ErrNotEnoughGold)
+	assert.ErrorIs(t, game.OfferTrade("alice", id, 0, []string{"coin"}), ErrItemNotFound)
+	assert.ErrorIs(t, game.OfferTrade("alice", id, 0, []string{"sword", "sword"}), ErrInvalidOffer)
+	assert.ErrorIs(t, game.OfferTrade("carol", id, 0, nil), ErrTradeNotFound)
+
+	require.NoError(t, game.LockTrade("alice", id))
+	require.NoError(t, game.CancelTrade("bob", id))
+	assert.Equal(t, int64(100), alice.GetResources().GetGold())
+	assert.Nil(t, game.tradeOf("alice"))
+	assert.Empty(t, audit.Records())
+}
+
+func TestAbandonedTradeExpires(t *testing.T) {
+	game, alice, _, _ := newTradeGame(t)
+	now := time.Unix(1_000_000, 0)
+	game.Clock = func() time.Time { return now }
+	id := openTrade(t, game)
+
+	require.NoError(t, game.OfferTrade("alice", id, 0, []string{"sword"}))
+	require.NoError(t, game.LockTrade("alice", id))
+
+	now = now.Add(TradeTimeout - time.Second)
+	game.Tick(time.Second)
+	assert.NotNil(t, game.tradeOf("alice"))
+
+	now = now.Add(time.Second)
+	game.Tick(time.Second)
+	assert.Nil(t, game.tradeOf("alice"))
+	require.Len(t, alice.GetInventory(), 1, "the escrowed item is back")
+}
+
+func TestLeavingPlayerGetsEscrowBack(t *testing.T) {
+	game, alice, _, _ := newTradeGame(t)
+	id := openTrade(t, game)
+	require.NoError(t, game.OfferTrade("alice", id, 40, nil))
+	require.NoError(t, game.LockTrade("alice", id))
+
+	game.DetachPlayer("alice", game.Players["alice"].Updates)
+	require.True(t, game.RemoveDetachedPlayer("alice"))
+	assert.Equal(t, int64(100), alice.GetResources().GetGold())
+	assert.Nil(t, game.tradeOf("bob"))
+}
+
+func TestEscrowIsSavedWithTheOwner(t *testing.T) {
+	game, alice, _, _ := newTradeGame(t)
+	id := openTrade(t, game)
+	require.NoError(t, game.OfferTrade("alice", id, 40, []string{"sword"}))
+	require.NoError(t, game.LockTrade("alice", id))
+
+	for _, snapshot := range game.SnapshotPlayers() {
+		if snapshot.GetId() == "alice" {
+			assert.Equal(t, int64(100), snapshot.GetResources().GetGold(), "escrowed gold is in the saved profile")
+			require.Len(t, snapshot.GetInventory(), 1)
+			assert.Equal(t, "sword", snapshot.GetInventory()[0].GetId())
+		}
+	}
+	assert.Equal(t, int64(60), alice.GetResources().GetGold(), "the live profile keeps the escrow out")
+
+	game.CancelTrades()
+	assert.Nil(t, game.tradeOf("alice"))
+	assert.Equal(t, int64(100), alice.GetResources().GetGold())
+	require.Len(t, alice.GetInventory(), 1)
+}
+
+func TestEscrowGoesBackToTheProfileOfAGoneSession(t *testing.T) {
+	game, alice, _, _ := newTradeGame(t)
+	id := openTrade(t, game)
+	require.NoError(t, game.OfferTrade("alice", id, 40, []string{"sword"}))
+	require.NoError(t, game.LockTrade("alice", id))
+
+	// a session dropped without cancelling the trade first
+	delete(game.Players, "alice")
+	require.NoError(t, game.CancelTrade("bob", id))
+	assert.Equal(t, int64(100), alice.GetResources().GetGold())
+	require.Len(t, alice.GetInventory(), 1)
+}
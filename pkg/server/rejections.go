@@ -0,0 +1,85 @@
+package server
+
+import (
+	pb "clicker/gen/proto"
+	"clicker/pkg/game"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	// a player may burst up to actionBurst actions, then actionsPerSecond on average
+	actionsPerSecond = 20
+	actionBurst      = 30
+	// chat has its own, much slower bucket so clicking does not eat into it
+	chatPerSecond = 0.5
+	chatBurst     = 5
+)
+
+var (
+	errRateLimited  = errors.New("too many actions, slow down")
+	errChatLimited  = errors.New("too many chat messages, slow down")
+	errUnknownEvent = errors.New("unknown action")
+)
+
+// actionLimiter is a token bucket for the actions of one stream, only used from its receive loop
+type actionLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newActionLimiter() *actionLimiter {
+	return &actionLimiter{rate: actionsPerSecond, burst: actionBurst, tokens: actionBurst, last: time.Now()}
+}
+
+func newChatLimiter() *actionLimiter {
+	return &actionLimiter{rate: chatPerSecond, burst: chatBurst, tokens: chatBurst, last: time.Now()}
+}
+
+func (l *actionLimiter) allow(now time.Time) bool {
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+func rejectionCode(err error) pb.RejectionCode {
+	switch {
+	case errors.Is(err, game.ErrNotEnoughGold):
+		return pb.RejectionCode_REJECTION_CODE_INSUFFICIENT_GOLD
+	case errors.Is(err, game.ErrEnemyNotFound), errors.Is(err, game.ErrItemNotFound), errors.Is(err, game.ErrPlayerNotFound),
+		errors.Is(err, game.ErrUnknownCompanion), errors.Is(err, game.ErrUnknownOffer),
+		errors.Is(err, game.ErrQuestNotFound), errors.Is(err, game.ErrGuildNotFound), errors.Is(err, game.ErrUnknownGuildBuff),
+		errors.Is(err, game.ErrTradeNotFound):
+		return pb.RejectionCode_REJECTION_CODE_UNKNOWN_TARGET
+	case errors.Is(err, errRateLimited), errors.Is(err, errChatLimited):
+		return pb.RejectionCode_REJECTION_CODE_RATE_LIMITED
+	case errors.Is(err, game.ErrEmptyMessage), errors.Is(err, game.ErrMessageTooLong), errors.Is(err, game.ErrMessageRejected),
+		errors.Is(err, game.ErrInvalidGuildName), errors.Is(err, game.ErrInvalidTaxRate), errors.Is(err, game.ErrInvalidGuildRole),
+		errors.Is(err, game.ErrInvalidOffer), errors.Is(err, game.ErrTradeWithSelf):
+		return pb.RejectionCode_REJECTION_CODE_INVALID_ARGUMENT
+	default:
+		return pb.RejectionCode_REJECTION_CODE_INVALID_STATE
+	}
+}
+
+// rejectAction tells the player why his action was not done
+func (gs *GameServer) rejectAction(g *game.Game, player *pb.Player, req *pb.ClientToServer, err error) {
+	code := rejectionCode(err)
+	log.Printf("Action %T of player %s rejected (%s): %v", req.GetEvent(), player.GetId(), code, err)
+	g.SendToPlayer(player.GetId(), &pb.ServerToClient{
+		Event: &pb.ServerToClient_ActionRejected{
+			ActionRejected: &pb.ActionRejected{
+				CorrelationId: req.GetCorrelationId(),
+				Code:          code,
+				Reason:        err.Error(),
+			},
+		},
+	})
+}
@@ -4,7 +4,14 @@ package server
 import (
 	pb "clicker/gen/proto"
 	"clicker/pkg/game"
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -12,11 +19,22 @@ import (
 
 type GameServer struct {
 	pb.UnimplementedGameServiceServer
-	game *game.Game
+	rooms  *game.RoomManager
+	store  game.PlayerStore
+	guilds *game.GuildRegistry
+	// shared by the outboxes of every stream
+	delivery *game.DeliveryMetrics
+	// a periodic save must not overwrite the final save of a player who just left
+	saveMu sync.Mutex
 }
 
-func NewGameServer(game *game.Game) *GameServer {
-	return &GameServer{game: game}
+func NewGameServer(rooms *game.RoomManager, store game.PlayerStore, guilds *game.GuildRegistry) *GameServer {
+	return &GameServer{rooms: rooms, store: store, guilds: guilds, delivery: &game.DeliveryMetrics{}}
+}
+
+// DeliveryMetrics tells how the updates to the clients are doing
+func (gs *GameServer) DeliveryMetrics() *game.DeliveryMetrics {
+	return gs.delivery
 }
 
 func (gs *GameServer) PlayGame(stream pb.GameService_PlayGameServer) error {
@@ -26,91 +44,445 @@ func (gs *GameServer) PlayGame(stream pb.GameService_PlayGameServer) error {
 		return err
 	}
 
-	selfInfo := initialReq.GetSelfInfo()
-	if selfInfo == nil {
-		return status.Errorf(codes.InvalidArgument, "Handshake failed: client must provide self_info")
+	var room *game.Room
+	var player *pb.Player
+	var outbox *game.Outbox
+	switch event := initialReq.GetEvent().(type) {
+	case *pb.ClientToServer_SelfInfo:
+		room, player, outbox, err = gs.joinGame(stream, event.SelfInfo)
+	case *pb.ClientToServer_Resume:
+		room, player, outbox, err = gs.resumeGame(stream, event.Resume)
+	default:
+		return status.Errorf(codes.InvalidArgument, "Handshake failed: client must provide self_info or resume")
+	}
+	if err != nil {
+		return err
 	}
 
-	player := game.InitializePlayer(selfInfo.GetName())
-	log.Printf("Player '%s' connecting with generated ID: %s", player.GetName(), player.GetId())
-
-	updatesChan := make(chan *pb.ServerToClient, 10)
-	gs.game.AddPlayer(player, updatesChan)
 	defer func() {
-		gs.game.RemovePlayer(player.GetId())
-		gs.game.Broadcast(&pb.ServerToClient{
-			Event: &pb.ServerToClient_PlayerLeft{
-				PlayerLeft: &pb.PlayerLeft{
-					PlayerId: player.GetId(),
-				},
-			},
-		}, "")
-		log.Printf("Player %s (ID: %s) disconnected\n", player.GetName(), player.GetId())
+		resumed := room.Game.DetachPlayer(player.GetId(), outbox)
+		if resumed == nil {
+			// another stream already took over this session
+			return
+		}
+		log.Printf("Player %s (ID: %s) lost connection, keeping session for %s\n", player.GetName(), player.GetId(), game.SessionGracePeriod)
+		go gs.awaitResume(room, player, resumed)
 	}()
 
+	// actions are read in the background, only this goroutine writes to the stream
+	received := make(chan error, 1)
 	go func() {
-		for update := range updatesChan {
-			if err := stream.Send(update); err != nil {
-				log.Printf("Error sending update to player %s: %v", player.GetId(), err)
-				return
+		received <- gs.receiveActions(stream, room, player)
+		outbox.Close()
+	}()
+
+	err = gs.sendUpdates(stream, player.GetId(), outbox)
+	switch {
+	case errors.Is(err, game.ErrSlowConsumer):
+		log.Printf("Player %s (ID: %s) is too slow to receive updates, closing the stream", player.GetName(), player.GetId())
+		return status.Errorf(codes.ResourceExhausted, "Too many pending updates, resume the session")
+	case errors.Is(err, game.ErrOutboxClosed):
+		select {
+		case err := <-received:
+			return err
+		default:
+			// another stream took over the session
+			return nil
+		}
+	default:
+		return err
+	}
+}
+
+func (gs *GameServer) receiveActions(stream pb.GameService_PlayGameServer, room *game.Room, player *pb.Player) error {
+	limiter := newActionLimiter()
+	chatLimiter := newChatLimiter()
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			log.Printf("Stream for player %s closed: %v", player.GetId(), err)
+			return err
+		}
+
+		if !limiter.allow(time.Now()) {
+			gs.rejectAction(room.Game, player, req, errRateLimited)
+			continue
+		}
+		if req.GetChat() != nil && !chatLimiter.allow(time.Now()) {
+			gs.rejectAction(room.Game, player, req, errChatLimited)
+			continue
+		}
+		if err := gs.handleAction(room.Game, player, req); err != nil {
+			gs.rejectAction(room.Game, player, req, err)
+		}
+	}
+}
+
+func (gs *GameServer) handleAction(g *game.Game, player *pb.Player, req *pb.ClientToServer) error {
+	switch event := req.GetEvent().(type) {
+	case *pb.ClientToServer_Attack:
+		return g.Attack(event.Attack.GetEnemyId(), player.GetId())
+	case *pb.ClientToServer_UpgradeWeapon:
+		return g.UpgradeWeapon(player.GetId())
+	case *pb.ClientToServer_EquipItem:
+		return g.EquipItem(player.GetId(), event.EquipItem.GetItemId())
+	case *pb.ClientToServer_UnequipItem:
+		return g.UnequipItem(player.GetId(), event.UnequipItem.GetSlot())
+	case *pb.ClientToServer_HireCompanion:
+		return g.HireCompanion(player.GetId(), event.HireCompanion.GetCompanionId())
+	case *pb.ClientToServer_ListShop:
+		g.SendToPlayer(player.GetId(), &pb.ServerToClient{
+			Event: &pb.ServerToClient_ShopList{ShopList: &pb.ShopList{Offers: g.ShopOffers()}},
+		})
+		return nil
+	case *pb.ClientToServer_Buy:
+		return g.Buy(player.GetId(), event.Buy.GetOfferId())
+	case *pb.ClientToServer_ClaimQuest:
+		return g.ClaimQuest(player.GetId(), event.ClaimQuest.GetQuestId())
+	case *pb.ClientToServer_Rebirth:
+		return g.Rebirth(player.GetId())
+	case *pb.ClientToServer_Chat:
+		return g.SendChat(player.GetId(), event.Chat.GetText())
+	case *pb.ClientToServer_CreateGuild:
+		return gs.guildAction(player, func() error { return g.CreateGuild(player.GetId(), event.CreateGuild.GetName()) })
+	case *pb.ClientToServer_JoinGuild:
+		return gs.guildAction(player, func() error { return g.JoinGuild(player.GetId(), event.JoinGuild.GetGuildId()) })
+	case *pb.ClientToServer_LeaveGuild:
+		return gs.guildAction(player, func() error { return g.LeaveGuild(player.GetId()) })
+	case *pb.ClientToServer_SetGuildRole:
+		return gs.guildAction(player, func() error {
+			return g.SetGuildRole(player.GetId(), event.SetGuildRole.GetPlayerId(), event.SetGuildRole.GetRole())
+		})
+	case *pb.ClientToServer_SetGuildTax:
+		return gs.guildAction(player, func() error { return g.SetGuildTax(player.GetId(), event.SetGuildTax.GetTaxRate()) })
+	case *pb.ClientToServer_BuyGuildBuff:
+		return gs.guildAction(player, func() error { return g.BuyGuildBuff(player.GetId(), event.BuyGuildBuff.GetBuffId()) })
+	case *pb.ClientToServer_ProposeTrade:
+		return g.ProposeTrade(player.GetId(), event.ProposeTrade.GetPartnerId())
+	case *pb.ClientToServer_OfferTrade:
+		return g.OfferTrade(player.GetId(), event.OfferTrade.GetTradeId(), event.OfferTrade.GetGold(), event.OfferTrade.GetItemIds())
+	case *pb.ClientToServer_LockTrade:
+		return g.LockTrade(player.GetId(), event.LockTrade.GetTradeId())
+	case *pb.ClientToServer_ConfirmTrade:
+		return g.ConfirmTrade(player.GetId(), event.ConfirmTrade.GetTradeId())
+	case *pb.ClientToServer_CancelTrade:
+		return g.CancelTrade(player.GetId(), event.CancelTrade.GetTradeId())
+	case *pb.ClientToServer_AllocateStatPoints:
+		return g.AllocateStatPoints(player.GetId(), event.AllocateStatPoints.GetAttribute(), event.AllocateStatPoints.GetPoints())
+	default:
+		return fmt.Errorf("%w %T", errUnknownEvent, event)
+	}
+}
+
+func (gs *GameServer) joinGame(stream pb.GameService_PlayGameServer, selfInfo *pb.Player) (*game.Room, *pb.Player, *game.Outbox, error) {
+	player, returning, err := game.LoadOrInitializePlayer(gs.store, selfInfo)
+	if err != nil {
+		log.Printf("Could not load profile for '%s': %v", selfInfo.GetName(), err)
+		return nil, nil, nil, status.Errorf(codes.Internal, "Could not load player profile")
+	}
+
+	room, err := gs.rooms.JoinPlayer(player.GetId(), selfInfo.GetRoomId())
+	switch {
+	case errors.Is(err, game.ErrAlreadyPlaying):
+		// the client restarted or lost its token while the old session was still there,
+		// the secret proves the profile is his, so the new stream takes the session over
+		if token, ok := room.Game.SessionToken(player.GetId()); ok {
+			return gs.resumeGame(stream, &pb.ResumeSession{SessionToken: token})
+		}
+		// the other stream is still joining
+		return nil, nil, nil, status.Errorf(codes.AlreadyExists, "Player %s is already playing", player.GetName())
+	case errors.Is(err, game.ErrRoomNotFound):
+		return nil, nil, nil, status.Errorf(codes.NotFound, "Room %s does not exist", selfInfo.GetRoomId())
+	case errors.Is(err, game.ErrRoomFull):
+		return nil, nil, nil, status.Errorf(codes.ResourceExhausted, "Room %s is full", selfInfo.GetRoomId())
+	case err != nil:
+		return nil, nil, nil, status.Errorf(codes.Internal, "Could not join the room")
+	}
+	player.RoomId = room.ID
+	// the guild could have changed while the player was away, e.g. he became its leader
+	player.GuildId = gs.guilds.GuildOf(player.GetId())
+	// guild buffs do not work offline, the game sets the bonus again once the player is in
+	player.GuildDamageBonus = 0
+
+	var welcomeBack *pb.WelcomeBack
+	if returning {
+		log.Printf("Player '%s' returned with saved profile ID: %s", player.GetName(), player.GetId())
+		welcomeBack = game.ApplyOfflineProgress(player, time.Now())
+	} else {
+		log.Printf("Player '%s' connecting with generated ID: %s", player.GetName(), player.GetId())
+		if err := gs.store.Save(player); err != nil {
+			log.Printf("Could not save new player %s: %v", player.GetId(), err)
+		}
+	}
+
+	if room.Game.GetCurrentEnemy() == nil {
+		gs.rooms.Leave(room, player.GetId())
+		return nil, nil, nil, status.Errorf(codes.Unavailable, "No enemies in the game")
+	}
+
+	outbox := game.NewOutbox(gs.delivery)
+	token := room.Game.AddPlayer(player, outbox)
+	log.Printf("Player %s (ID: %s) joined room '%s'", player.GetName(), player.GetId(), room.Name)
+
+	gs.sendWelcome(room, player, token)
+	if welcomeBack != nil {
+		log.Printf("Player %s earned %d gold and %d exp offline", player.GetName(), welcomeBack.GetGold(), welcomeBack.GetExperience())
+		room.Game.SendToPlayer(player.GetId(), &pb.ServerToClient{
+			Event: &pb.ServerToClient_WelcomeBack{WelcomeBack: welcomeBack},
+		})
+	}
+	gs.sendInitialState(room.Game, player)
+	gs.sendGuild(room.Game, player.GetId())
+
+	playerJoinedMsg := &pb.ServerToClient{
+		Event: &pb.ServerToClient_PlayerJoined{
+			PlayerJoined: &pb.PlayerJoined{
+				Player: player,
+			},
+		},
+	}
+	room.Game.Broadcast(playerJoinedMsg, player.GetId())
+
+	return room, player, outbox, nil
+}
+
+// resumeGame reattaches the stream to a session kept after a disconnect
+func (gs *GameServer) resumeGame(stream pb.GameService_PlayGameServer, resume *pb.ResumeSession) (*game.Room, *pb.Player, *game.Outbox, error) {
+	outbox := game.NewOutbox(gs.delivery)
+	// tokens are random, so it is fine to ask every room
+	for _, room := range gs.rooms.Rooms() {
+		player, missed, complete, err := room.Game.ResumeSession(resume.GetSessionToken(), resume.GetLastSeq(), outbox)
+		if errors.Is(err, game.ErrSessionNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, nil, status.Errorf(codes.Internal, "Could not resume session")
+		}
+		log.Printf("Player %s (ID: %s) resumed session, replaying %d events\n", player.GetName(), player.GetId(), len(missed))
+
+		// missed events go out before anything queued after the resume
+		for _, msg := range missed {
+			if err := stream.Send(msg); err != nil {
+				log.Printf("Error replaying update to player %s: %v", player.GetId(), err)
+				break
 			}
 		}
-	}()
 
-	welcomeMsg := &pb.ServerToClient{
+		gs.sendWelcome(room, player, resume.GetSessionToken())
+		if !complete {
+			gs.sendInitialState(room.Game, player)
+			gs.sendGuild(room.Game, player.GetId())
+		}
+
+		return room, player, outbox, nil
+	}
+	return nil, nil, nil, status.Errorf(codes.NotFound, "Session expired, join the game again")
+}
+
+// sendUpdates writes the outbox to the stream until it is closed or the stream breaks
+func (gs *GameServer) sendUpdates(stream pb.GameService_PlayGameServer, playerID string, outbox *game.Outbox) error {
+	for {
+		update, err := outbox.Next(stream.Context())
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(update); err != nil {
+			log.Printf("Error sending update to player %s: %v", playerID, err)
+			return err
+		}
+	}
+}
+
+func (gs *GameServer) sendWelcome(room *game.Room, player *pb.Player, token string) {
+	room.Game.SendToPlayer(player.GetId(), &pb.ServerToClient{
 		Event: &pb.ServerToClient_Welcome{
 			Welcome: &pb.Welcome{
-				Player: player,
+				Player:       player,
+				SessionToken: token,
+				Room:         gs.rooms.Info(room),
 			},
 		},
-	}
-	updatesChan <- welcomeMsg
+	})
 	log.Printf("Sent Welcome message to %s\n", player.GetName())
+}
 
-	currentEnemy := gs.game.GetCurrentEnemy()
-	if currentEnemy == nil {
-		return status.Errorf(codes.Unavailable, "No enemies in the game")
+func (gs *GameServer) sendInitialState(g *game.Game, player *pb.Player) {
+	activeEnemies := g.GetActiveEnemies()
+	enemies := make([]*pb.Enemy, 0, len(activeEnemies))
+	for _, enemy := range activeEnemies {
+		enemies = append(enemies, enemy.ToProto())
 	}
-	allPlayers := gs.game.GetAllPlayers()
 
-	initState := &pb.ServerToClient{
+	g.SendToPlayer(player.GetId(), &pb.ServerToClient{
 		Event: &pb.ServerToClient_InitialState{
 			InitialState: &pb.InitialState{
-				Enemy:   currentEnemy.ToProto(),
-				Players: allPlayers,
+				Enemies: enemies,
+				Players: g.GetAllPlayers(),
+				Stage:   g.CurrentStage(),
+				Chat:    g.ChatHistory(),
 			},
 		},
-	}
-	updatesChan <- initState
+	})
 	log.Printf("Sent initial state to player %s\n", player.GetId())
+}
 
-	playerJoinedMsg := &pb.ServerToClient{
-		Event: &pb.ServerToClient_PlayerJoined{
-			PlayerJoined: &pb.PlayerJoined{
-				Player: player,
+// awaitResume removes the player for good if he does not come back in time
+func (gs *GameServer) awaitResume(room *game.Room, player *pb.Player, resumed <-chan struct{}) {
+	disconnectedAt := time.Now()
+	select {
+	case <-resumed:
+		return
+	case <-time.After(game.SessionGracePeriod):
+	}
+
+	gs.saveMu.Lock()
+	defer gs.saveMu.Unlock()
+	if !room.Game.RemoveDetachedPlayer(player.GetId()) {
+		return
+	}
+	player.LastSeen = disconnectedAt.Unix()
+	if err := gs.store.Save(player); err != nil {
+		log.Printf("Could not save player %s: %v", player.GetId(), err)
+	}
+	// the profile is only free to join again once it is saved, a rejoin loads the saved copy
+	gs.rooms.Leave(room, player.GetId())
+	room.Game.Broadcast(&pb.ServerToClient{
+		Event: &pb.ServerToClient_PlayerLeft{
+			PlayerLeft: &pb.PlayerLeft{
+				PlayerId: player.GetId(),
 			},
 		},
+	}, "")
+	log.Printf("Player %s (ID: %s) disconnected\n", player.GetName(), player.GetId())
+}
+
+// SaveSessions saves the profile of everyone still in the game, players are otherwise
+// only saved when they leave
+func (gs *GameServer) SaveSessions() {
+	gs.saveMu.Lock()
+	defer gs.saveMu.Unlock()
+	// offline progress starts at the LastSeen of the snapshots if the server never comes back
+	players := gs.rooms.Sessions()
+	for _, player := range players {
+		if err := gs.store.Save(player); err != nil {
+			log.Printf("Could not save player %s: %v", player.GetId(), err)
+		}
 	}
-	gs.game.Broadcast(playerJoinedMsg, player.GetId())
+	log.Printf("Saved %d players", len(players))
+}
 
+// RunSessionSaver saves the sessions every interval until ctx is done
+func (gs *GameServer) RunSessionSaver(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
-		req, err := stream.Recv()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			gs.SaveSessions()
+		}
+	}
+}
+
+func (gs *GameServer) GetLeaderboard(ctx context.Context, req *pb.LeaderboardRequest) (*pb.Leaderboard, error) {
+	room, err := gs.rooms.Room(req.GetRoomId())
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "Room %s does not exist", req.GetRoomId())
+	}
+	var offline []*pb.Player
+	if req.GetScope() == pb.LeaderboardScope_LEADERBOARD_SCOPE_ALL_TIME {
+		stored, err := gs.store.List()
 		if err != nil {
-			log.Printf("Stream for player %s closed: %v", player.GetId(), err)
-			return err
+			log.Printf("Could not list players for the leaderboard: %v", err)
+			return nil, status.Errorf(codes.Internal, "Could not load player profiles")
+		}
+		// players online in any room are ahead of their saved profiles
+		profiles := make(map[string]*pb.Player, len(stored))
+		for _, player := range stored {
+			profiles[player.GetId()] = player
+		}
+		for _, player := range gs.rooms.Sessions() {
+			profiles[player.GetId()] = player
 		}
+		offline = slices.Collect(maps.Values(profiles))
+	}
+	return room.Game.Leaderboard(req.GetCategory(), req.GetScope(), int(req.GetLimit()), offline), nil
+}
 
-		switch req.GetEvent().(type) {
-		case *pb.ClientToServer_Attack:
-			weapon := player.GetEquipment().GetWeapon()
-			damage := weapon.GetBaseDamage() + weapon.GetDamageGrowth()*float32(weapon.GetLevel()-1)
-			gs.game.ApplyDamage("", float64(damage), player.GetId())
+func (gs *GameServer) ListRooms(ctx context.Context, req *pb.ListRoomsRequest) (*pb.RoomList, error) {
+	return &pb.RoomList{Rooms: gs.rooms.ListRooms()}, nil
+}
 
-		case *pb.ClientToServer_UpgradeWeapon:
-			gs.game.UpgradeWeapon(player.GetId())
+func (gs *GameServer) CreateRoom(ctx context.Context, req *pb.CreateRoomRequest) (*pb.RoomInfo, error) {
+	if req.GetCapacity() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Capacity must not be negative")
+	}
+	room, err := gs.rooms.CreateRoom(req.GetName(), int(req.GetCapacity()))
+	if errors.Is(err, game.ErrTooManyRooms) {
+		return nil, status.Errorf(codes.ResourceExhausted, "Too many rooms, try again later")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not create the room")
+	}
+	return gs.rooms.Info(room), nil
+}
 
-		default:
-			log.Printf("Received unhandled event type from player %s\n", player.GetName())
+// guildAction runs a guild action and tells every online member of the guilds it touched
+func (gs *GameServer) guildAction(player *pb.Player, action func() error) error {
+	before := gs.guilds.GuildOf(player.GetId())
+	if err := action(); err != nil {
+		return err
+	}
+	after := gs.guilds.GuildOf(player.GetId())
+
+	if before != "" && before != after {
+		gs.notifyGuild(before)
+	}
+	if after != "" {
+		gs.notifyGuild(after)
+	} else if room, ok := gs.rooms.FindPlayer(player.GetId()); ok {
+		gs.sendGuild(room.Game, player.GetId())
+	}
+	return nil
+}
+
+// notifyGuild sends the guild to its members in every room
+func (gs *GameServer) notifyGuild(guildID string) {
+	guild, err := gs.guilds.Guild(guildID)
+	if err != nil {
+		// the last member left and the guild is gone
+		return
+	}
+	update := &pb.ServerToClient{Event: &pb.ServerToClient_GuildUpdate{GuildUpdate: &pb.GuildUpdate{Guild: guild}}}
+	for _, member := range guild.GetMembers() {
+		if room, ok := gs.rooms.FindPlayer(member.GetPlayerId()); ok {
+			room.Game.SendToPlayer(member.GetPlayerId(), update)
 		}
 	}
 }
+
+// sendGuild tells the player which guild he is in, an empty update means none
+func (gs *GameServer) sendGuild(g *game.Game, playerID string) {
+	update := &pb.GuildUpdate{}
+	if guildID := gs.guilds.GuildOf(playerID); guildID != "" {
+		update.Guild, _ = gs.guilds.Guild(guildID)
+	}
+	g.SendToPlayer(playerID, &pb.ServerToClient{Event: &pb.ServerToClient_GuildUpdate{GuildUpdate: update}})
+}
+
+func (gs *GameServer) ListGuilds(ctx context.Context, req *pb.ListGuildsRequest) (*pb.GuildList, error) {
+	offers := make([]*pb.GuildBuffOffer, 0, len(game.GuildBuffOffers))
+	for _, offer := range game.GuildBuffOffers {
+		offers = append(offers, offer.ToProto())
+	}
+	return &pb.GuildList{Guilds: gs.guilds.List(), BuffOffers: offers}, nil
+}
+
+func (gs *GameServer) GetGuild(ctx context.Context, req *pb.GetGuildRequest) (*pb.Guild, error) {
+	guild, err := gs.guilds.Guild(req.GetGuildId())
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "Guild %s does not exist", req.GetGuildId())
+	}
+	return guild, nil
+}